@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"snippetbox.example.org/internal/models"
+	"snippetbox.example.org/internal/validator"
+)
+
+// The apiSnippet struct is the JSON representation of a snippet which is sent
+// by the API. We build it from a models.Snippet rather than adding JSON tags
+// to the model itself, so that the API stays the same if the model changes.
+// Expires and ForkedFrom are null for snippets which never expire or weren't
+// forked, and Content and Files are left out of password-protected snippets
+// which the client hasn't unlocked. URL is the absolute address of the
+// snippet's page, built from the configured public URL.
+type apiSnippet struct {
+	ID               int        `json:"id"`
+	Title            string     `json:"title"`
+	Content          string     `json:"content,omitempty"`
+	Language         string     `json:"language"`
+	Visibility       string     `json:"visibility"`
+	Created          time.Time  `json:"created"`
+	Expires          *time.Time `json:"expires"`
+	BurnAfterReading bool       `json:"burn_after_reading"`
+	Protected        bool       `json:"protected"`
+	UserID           int        `json:"user_id"`
+	UserName         string     `json:"user_name"`
+	URL              string     `json:"url"`
+	Files            []apiFile  `json:"files,omitempty"`
+	ForkedFrom       *int       `json:"forked_from"`
+	Forks            int        `json:"forks"`
+	Tags             []string   `json:"tags"`
+	Stars            int        `json:"stars"`
+}
+
+// The apiFile struct is the JSON representation of one of a snippet's extra
+// files.
+type apiFile struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+func (app *application) newAPISnippet(r *http.Request, snippet *models.Snippet) apiSnippet {
+	s := apiSnippet{
+		ID:               snippet.ID,
+		Title:            snippet.Title,
+		Content:          snippet.Content,
+		Language:         snippet.Language,
+		Visibility:       snippet.Visibility,
+		Created:          snippet.Created,
+		BurnAfterReading: snippet.BurnAfterReading,
+		Protected:        snippet.Protected,
+		UserID:           snippet.UserID,
+		UserName:         snippet.UserName,
+		URL:              fmt.Sprintf("%s/snippet/view/%d", app.publicURL, snippet.ID),
+		Forks:            snippet.Forks,
+		Tags:             snippet.Tags,
+		Stars:            snippet.Stars,
+	}
+
+	if !snippet.Expires.IsZero() {
+		s.Expires = &snippet.Expires
+	}
+
+	if snippet.ForkedFrom != 0 {
+		s.ForkedFrom = &snippet.ForkedFrom
+	}
+
+	for _, f := range snippet.Files {
+		s.Files = append(s.Files, apiFile{Name: f.Name, Language: f.Language, Content: f.Content})
+	}
+
+	if app.isLocked(r, snippet) {
+		s.Content = ""
+		s.Files = nil
+	}
+
+	return s
+}
+
+// The apiSnippetList handler returns a page of public snippets. It accepts the
+// same page, page_size and sort query string parameters as the home page.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	v := validator.Validator{}
+	qs := r.URL.Query()
+
+	filters := models.Filters{
+		Page:     app.readInt(qs, "page", 1, &v),
+		PageSize: app.readInt(qs, "page_size", 20, &v),
+		Sort:     app.readString(qs, "sort", "-created"),
+	}
+
+	if models.ValidateFilters(&v, filters); !v.Valid() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.List(filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := make([]apiSnippet, 0, len(snippets))
+	for _, snippet := range snippets {
+		data = append(data, app.newAPISnippet(r, snippet))
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippets": data, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// The apiSnippetGet handler returns a single snippet. It follows the same
+// rules as the snippetView handler, so private snippets belonging to someone
+// else are reported as not found, and burn-after-reading snippets are
+// deleted once they have been returned.
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, _, err := app.viewSnippet(r, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippet": app.newAPISnippet(r, snippet)}, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// The apiSnippetCreate handler creates a snippet from a JSON request body. The
+// body is decoded into the same snippetCreateForm struct as the HTML form, so
+// the validation rules and error messages are identical. Fields which are
+// left out get the same defaults as the create form, so a snippet from an
+// unverified user is unlisted rather than public. Tags are sent as
+// comma-separated text, just like the form, although they come back as a
+// list.
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	visibility, err := app.defaultVisibility(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	form := snippetCreateForm{
+		Language:   "plaintext",
+		Visibility: visibility,
+		Expires:    "365d",
+	}
+
+	err = app.readJSON(w, r, &form)
+	if err != nil {
+		app.badRequestJSON(w, r, err)
+		return
+	}
+
+	expires := form.validate(time.Now())
+
+	err = app.checkFork(r, &form)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.checkVisibility(r, &form.Validator, form.Visibility)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		app.failedValidation(w, r, form.Validator)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	id, err := app.snippets.Insert(form.snippet(expires, userID))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Read the new snippet back so that the response includes everything
+	// the database filled in. We use Get() rather than View() so that a
+	// burn-after-reading snippet isn't deleted before anyone has seen it.
+	snippet, err := app.snippets.Get(id, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"snippet": app.newAPISnippet(r, snippet)}, headers)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
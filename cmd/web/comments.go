@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"snippetbox.example.org/internal/models"
+	"snippetbox.example.org/internal/validator"
+)
+
+// The commentForm struct holds the form data and validation errors for the
+// comment forms on the view page. ParentID is set on the reply form for a
+// thread, and Line can be set on the form for a new top-level comment.
+type commentForm struct {
+	Body                string `form:"body"`
+	Line                int    `form:"line"`
+	ParentID            int    `form:"parent_id"`
+	validator.Validator `form:"-"`
+}
+
+// The splitLines() function splits a snippet's content into lines. A final
+// newline doesn't start another line.
+func splitLines(content string) []string {
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// The renderSnippet() method renders the view page for a snippet along with
+// its comments, and whether the current user has starred it. The form is
+// used to re-display a comment form which failed validation.
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, status int, snippet *models.Snippet, form commentForm) {
+	comments, err := app.comments.ForSnippet(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	starred, err := app.stars.Exists(app.authenticatedUserID(r), snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Comments = comments
+	data.Starred = starred
+	data.Form = form
+
+	app.render(w, r, status, "view.tmpl", data)
+}
+
+// The commentCreatePost handler adds a comment to a snippet. People can only
+// comment on snippets they can see, and not on burn-after-reading snippets,
+// which have gone by the time anyone could read the comments.
+func (app *application) commentCreatePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if snippet.BurnAfterReading {
+		app.notFound(w, r)
+		return
+	}
+
+	if app.isLocked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+		return
+	}
+
+	var form commentForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Body), "body", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Body, 2000), "body", "This field cannot be more than 2000 characters long")
+
+	lines := len(splitLines(snippet.Content))
+	form.CheckField(form.Line >= 0 && form.Line <= lines, "line", fmt.Sprintf("This field must be a line number between 1 and %d", lines))
+
+	// Replies must be to a comment on the same snippet. Threads are only one
+	// level deep, so a reply to a reply joins the thread it's in, and only
+	// the comment which starts a thread can be about a particular line.
+	if form.ParentID != 0 {
+		parent, err := app.comments.Get(form.ParentID)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if parent == nil || parent.SnippetID != snippet.ID {
+			form.AddNonFieldErrors("The comment you replied to has been deleted")
+		} else if parent.ParentID != 0 {
+			form.ParentID = parent.ParentID
+		}
+
+		form.Line = 0
+	}
+
+	if !form.Valid() {
+		app.renderSnippet(w, r, http.StatusUnprocessableEntity, snippet, form)
+		return
+	}
+
+	commentID, err := app.comments.Insert(snippet.ID, app.authenticatedUserID(r), form.ParentID, form.Line, form.Body)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment added!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d#comment-%d", snippet.ID, commentID), http.StatusSeeOther)
+}
+
+// The commentDeletePost handler deletes one of the current user's comments.
+func (app *application) commentDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	comment, err := app.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if comment.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	err = app.comments.Delete(comment.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment deleted!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d#comments", comment.SnippetID), http.StatusSeeOther)
+}
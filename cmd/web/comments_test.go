@@ -0,0 +1,187 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestSnippetComments(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anyone can read the comments, but only logged-in users get the forms.
+	code, _, body := ts.get(t, "/snippet/view/1")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<strong>Bob Smith</strong> <span>on line 1</span>")
+	assert.StringContains(t, body, "<pre><code>An old silent pond...</code></pre>")
+	assert.StringContains(t, body, "It jumps in later.")
+	assert.StringContains(t, body, "<a href='/user/login'>Log in</a> to leave a comment.")
+
+	_, _, body = ts.get(t, "/snippet/view/3")
+	assert.StringContains(t, body, "There are no comments yet.")
+
+	ts.login(t)
+
+	// Alice wrote the reply, so she can delete it but not Bob's comment.
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<form action='/comment/delete/2' method='POST'>")
+	assert.Equal(t, strings.Contains(body, "<form action='/comment/delete/1' method='POST'>"), false)
+	assert.StringContains(t, body, "<input type='hidden' name='parent_id' value='1'>")
+}
+
+func TestCommentCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		body         string
+		line         string
+		parentID     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid comment",
+			urlPath:      "/snippet/comment/1",
+			body:         "Lovely.",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1#comment-3",
+		},
+		{
+			name:         "Line comment",
+			urlPath:      "/snippet/comment/1",
+			body:         "Lovely line.",
+			line:         "1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1#comment-3",
+		},
+		{
+			name:         "Reply",
+			urlPath:      "/snippet/comment/1",
+			body:         "Agreed.",
+			parentID:     "2",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1#comment-3",
+		},
+		{
+			name:     "Empty body",
+			urlPath:  "/snippet/comment/1",
+			body:     "",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name:     "Line out of range",
+			urlPath:  "/snippet/comment/1",
+			body:     "Lovely.",
+			line:     "2",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field must be a line number between 1 and 1",
+		},
+		{
+			name:     "Reply to another snippet's comment",
+			urlPath:  "/snippet/comment/3",
+			body:     "Agreed.",
+			parentID: "1",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "The comment you replied to has been deleted",
+		},
+		{
+			name:     "Burn after reading",
+			urlPath:  "/snippet/comment/4",
+			body:     "Lovely.",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Protected",
+			urlPath:      "/snippet/comment/7",
+			body:         "Lovely.",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/7",
+		},
+		{
+			name:     "Non-existent snippet",
+			urlPath:  "/snippet/comment/99",
+			body:     "Lovely.",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("line", tt.line)
+			form.Add("parent_id", tt.parentID)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCommentDeletePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Requests without a CSRF token are rejected.
+	code, headers, _ := ts.postForm(t, "/comment/delete/2", url.Values{})
+	assert.Equal(t, code, http.StatusBadRequest)
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Own comment",
+			urlPath:      "/comment/delete/2",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1#comments",
+		},
+		{
+			name:     "Someone else's comment",
+			urlPath:  "/comment/delete/1",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent comment",
+			urlPath:  "/comment/delete/99",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ = ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
@@ -3,3 +3,12 @@ package main
 type contextKey string
 
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+// We also store the ID of the authenticated user in the request context, so
+// that handlers don't need to reach back into the session to find out who is
+// making the request.
+const authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+
+// The requireSnippetOwner middleware has already fetched the snippet by the
+// time the handler runs, so it passes it along in the request context.
+const snippetContextKey = contextKey("snippet")
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.example.org/internal/models"
+)
+
+// The number of snippets to include in a feed. Feed readers only need to see
+// what's new since they last polled, so there's no paging.
+const feedSize = 20
+
+// The feed struct holds everything needed to write a feed in either format.
+// Updated is the most recent time that any snippet in the feed was created or
+// edited, and is the zero time if the feed is empty.
+type feed struct {
+	Title       string
+	Description string
+	Link        string
+	Self        string
+	Updated     time.Time
+	Snippets    []*models.Snippet
+}
+
+// The protectedSummary is shown in place of the content of password-protected
+// snippets, which we never put in a feed.
+const protectedSummary = "This snippet is protected by a password."
+
+// The loadFeed() method fetches the latest public snippets, in the same way as
+// the home page. If the route has an id parameter, only the snippets
+// belonging to that user are included. It sends an error response and
+// returns false if anything goes wrong. The links are built from the
+// configured public URL rather than the Host header, because feeds are
+// cached by shared proxies and the Atom feed ID must never change.
+func (app *application) loadFeed(w http.ResponseWriter, r *http.Request) (*feed, bool) {
+	f := &feed{
+		Title:       "Snippetbox",
+		Description: "The latest public snippets on Snippetbox",
+		Link:        app.publicURL + "/",
+		Self:        app.publicURL + r.URL.Path,
+	}
+
+	filters := models.Filters{Page: 1, PageSize: feedSize, Sort: "-created"}
+
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") != "" {
+		id, err := app.readIDParam(r)
+		if err != nil {
+			app.notFound(w, r)
+			return nil, false
+		}
+
+		user, err := app.users.Get(id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return nil, false
+		}
+
+		f.Title = fmt.Sprintf("Snippets by %s - Snippetbox", user.Name)
+		f.Description = fmt.Sprintf("The latest public snippets by %s on Snippetbox", user.Name)
+		f.Link = fmt.Sprintf("%s/user/profile/%d", app.publicURL, user.ID)
+		filters.UserID = user.ID
+	}
+
+	snippets, _, err := app.snippets.List(filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+
+	f.Snippets = snippets
+	for _, s := range snippets {
+		if s.Updated.After(f.Updated) {
+			f.Updated = s.Updated
+		}
+	}
+
+	return f, true
+}
+
+// The atomFeed, atomLink, atomEntry and atomText types describe an Atom 1.0
+// document (RFC 4287) for encoding/xml.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title     string    `xml:"title"`
+	ID        string    `xml:"id"`
+	Link      atomLink  `xml:"link"`
+	Published string    `xml:"published"`
+	Updated   string    `xml:"updated"`
+	Author    string    `xml:"author>name"`
+	Summary   *atomText `xml:"summary,omitempty"`
+	Content   *atomText `xml:"content,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// The atomTime() function formats a time as an RFC 3339 timestamp in UTC. An
+// empty feed still needs an updated time, so we use the Unix epoch for the
+// zero time, which keeps the feed (and so its ETag) the same between requests.
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// The feedAtom handler sends the feed as Atom.
+func (app *application) feedAtom(w http.ResponseWriter, r *http.Request) {
+	f, ok := app.loadFeed(w, r)
+	if !ok {
+		return
+	}
+
+	doc := atomFeed{
+		Title: f.Title,
+		ID:    f.Self,
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.Self},
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+		},
+		Updated: atomTime(f.Updated),
+		Entries: []atomEntry{},
+	}
+
+	for _, s := range f.Snippets {
+		url := fmt.Sprintf("%s/snippet/view/%d", app.publicURL, s.ID)
+
+		entry := atomEntry{
+			Title:     s.Title,
+			ID:        url,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: url},
+			Published: atomTime(s.Created),
+			Updated:   atomTime(s.Updated),
+			Author:    s.UserName,
+		}
+
+		if s.Protected {
+			entry.Summary = &atomText{Type: "text", Body: protectedSummary}
+		} else {
+			entry.Content = &atomText{Type: "text", Body: s.Content}
+		}
+
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	app.writeFeed(w, r, "application/atom+xml; charset=utf-8", doc)
+}
+
+// The rssFeed, rssChannel, rssItem and rssGUID types describe an RSS 2.0
+// document for encoding/xml.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// The feedRSS handler sends the feed as RSS. RSS has no way to say when an
+// item was edited, so an edited snippet only changes the lastBuildDate.
+func (app *application) feedRSS(w http.ResponseWriter, r *http.Request) {
+	f, ok := app.loadFeed(w, r)
+	if !ok {
+		return
+	}
+
+	doc := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: f.Description,
+			Items:       []rssItem{},
+		},
+	}
+
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, s := range f.Snippets {
+		url := fmt.Sprintf("%s/snippet/view/%d", app.publicURL, s.ID)
+
+		item := rssItem{
+			Title:       s.Title,
+			Link:        url,
+			GUID:        rssGUID{IsPermaLink: true, Value: url},
+			PubDate:     s.Created.UTC().Format(time.RFC1123Z),
+			Description: s.Content,
+		}
+
+		if s.Protected {
+			item.Description = protectedSummary
+		}
+
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	app.writeFeed(w, r, "application/rss+xml; charset=utf-8", doc)
+}
+
+// The writeFeed() method encodes a feed document as XML and sends it. The
+// ETag is a hash of the encoded feed, so it changes whenever anything in the
+// feed does, including when a snippet expires, is deleted or is made
+// private. We let http.ServeContent() deal with the If-None-Match header, so
+// that clients which poll the feed get a 304 Not Modified response when
+// nothing has changed. There's no Last-Modified header, because the newest
+// snippet in the feed doesn't say when one of the others was removed.
+func (app *application) writeFeed(w http.ResponseWriter, r *http.Request, contentType string, doc any) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	body = append([]byte(xml.Header), body...)
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(body)))
+
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"testing"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestFeedAtom(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantTitle string
+		wantIDs   []string
+	}{
+		{
+			name:      "All snippets",
+			urlPath:   "/feed.atom",
+			wantCode:  http.StatusOK,
+			wantTitle: "Snippetbox",
+			wantIDs:   []string{"https://snippetbox.example.org/snippet/view/3", "https://snippetbox.example.org/snippet/view/1"},
+		},
+		{
+			name:      "User",
+			urlPath:   "/user/profile/1/feed.atom",
+			wantCode:  http.StatusOK,
+			wantTitle: "Snippets by Alice Jones - Snippetbox",
+			wantIDs:   []string{"https://snippetbox.example.org/snippet/view/1"},
+		},
+		{
+			name:     "Non-existent user",
+			urlPath:  "/user/profile/99/feed.atom",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid user ID",
+			urlPath:  "/user/profile/foo/feed.atom",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, headers.Get("Content-Type"), "application/atom+xml; charset=utf-8")
+			assert.Equal(t, headers.Get("Cache-Control"), "public, max-age=300")
+			assert.Equal(t, headers.Get("Set-Cookie"), "")
+			assert.Equal(t, headers.Get("Last-Modified"), "")
+
+			var feed atomFeed
+			err := xml.Unmarshal([]byte(body), &feed)
+			assert.NilError(t, err)
+
+			assert.Equal(t, feed.Title, tt.wantTitle)
+			assert.Equal(t, len(feed.Entries), len(tt.wantIDs))
+			for i, entry := range feed.Entries {
+				assert.Equal(t, entry.ID, tt.wantIDs[i])
+			}
+		})
+	}
+}
+
+func TestFeedRSS(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/feed.rss")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.Equal(t, headers.Get("Content-Type"), "application/rss+xml; charset=utf-8")
+
+	var feed rssFeed
+	err := xml.Unmarshal([]byte(body), &feed)
+	assert.NilError(t, err)
+
+	assert.Equal(t, feed.Version, "2.0")
+	assert.Equal(t, len(feed.Channel.Items), 2)
+	assert.Equal(t, feed.Channel.Items[1].Title, "An old silent pond")
+	assert.Equal(t, feed.Channel.Items[1].Description, "An old silent pond...")
+	assert.Equal(t, feed.Channel.Items[1].GUID.Value, "https://snippetbox.example.org/snippet/view/1")
+}
+
+func TestFeedIgnoresHost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// A forged Host header mustn't end up in the links, or a shared cache
+	// could hand them out to everyone.
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/feed.atom", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "evil.example.com"
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	var feed atomFeed
+	err = xml.NewDecoder(rs.Body).Decode(&feed)
+	assert.NilError(t, err)
+
+	assert.Equal(t, feed.ID, "https://snippetbox.example.org/feed.atom")
+	assert.Equal(t, feed.Entries[0].ID, "https://snippetbox.example.org/snippet/view/3")
+}
+
+func TestFeedNotModified(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, headers, _ := ts.get(t, "/feed.atom")
+
+	etag := headers.Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag header")
+	}
+
+	// A client which sends the ETag back should get an empty 304 response.
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/feed.atom", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-None-Match", etag)
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, rs.StatusCode, http.StatusNotModified)
+	assert.Equal(t, len(body), 0)
+}
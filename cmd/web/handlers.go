@@ -3,10 +3,16 @@ package main
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/julienschmidt/httprouter"
+	"snippetbox.example.org/internal/diff"
+	"snippetbox.example.org/internal/highlight"
 	"snippetbox.example.org/internal/models"
 	"snippetbox.example.org/internal/validator"
 )
@@ -17,21 +23,40 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// Because httprouter matches the "/" path exactly, we can now remove the
 	// manual check of r.URL.Path != "/" from this handler.
 
-	snippets, err := app.snippets.Lastest()
+	// Read the paging and sorting options from the query string, falling back
+	// to the first page of the newest snippets. If any of them are invalid we
+	// send a 400 Bad Request response.
+	v := validator.Validator{}
+	qs := r.URL.Query()
+
+	filters := models.Filters{
+		Page:     app.readInt(qs, "page", 1, &v),
+		PageSize: app.readInt(qs, "page_size", 10, &v),
+		Sort:     app.readString(qs, "sort", "-created"),
+	}
+
+	if models.ValidateFilters(&v, filters); !v.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.List(filters)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
 	// Call the newTemplateData() helper to get a templateData struct containing
 	// the 'default' data (which for now is just the current year), and add the
-	// snippet slice to it.
+	// snippet slice, filters and pagination metadata to it.
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.Filters = filters
+	data.Metadata = metadata
 
 	// Use the new render helper.
 	// Pass the data to the render() helper as normal.
-	app.render(w, http.StatusOK, "home.tmpl", data)
+	app.render(w, r, http.StatusOK, "home.tmpl", data)
 }
 
 // Change the signature of the snippetView handler so it is defined as a method
@@ -41,36 +66,458 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	// will be stored in the request context. We'll talk about request context
 	// in detail later in the book, but for now it's enough to know that you can
 	// use the ParamFromContext() function to retrieve a slice containing these
-	// parameter names and values like so:
-	params := httprouter.ParamsFromContext((r.Context()))
-
-	// We can then use the ByName() method to get the value of the "id" named
+	// parameter names and values. Our readIDParam() helper does exactly that,
+	// then uses the ByName() method to get the value of the "id" named
 	// parameter from the slice and validate it as normal.
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < -1 {
-		app.notFound(w)
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	// Use the viewSnippet() helper to retrieve the data for a specific record
+	// based on its ID. If no matching record is found, or the snippet is
+	// private and belongs to someone else, return a 404 Not Found response.
+	snippet, locked, err := app.viewSnippet(r, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// If the snippet has a password which the user hasn't entered yet, show
+	// them the unlock form instead.
+	if locked {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetUnlockForm{}
+		app.render(w, r, http.StatusOK, "unlock.tmpl", data)
+		return
+	}
+
+	// Use the renderSnippet() helper, which also fetches the comments.
+	app.renderSnippet(w, r, http.StatusOK, snippet, commentForm{})
+}
+
+// The snippetUnlockForm struct holds the password entered on the unlock form
+// for a protected snippet.
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// The snippetUnlockPost handler checks the password for a protected snippet.
+// If it's correct we remember the unlock in the user's session and send them
+// back to the snippet. Failed attempts are counted per snippet rather than
+// per user, so that someone can't get around the limit by clearing their
+// cookies.
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// There's nothing to do if the snippet has no password, or the user can
+	// already see it.
+	if !app.isLocked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+		return
+	}
+
+	var form snippetUnlockForm
+
+	err = app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// Count the attempt before checking the password, so that requests made
+	// in parallel can't all get in under the limit. Once there have been too
+	// many wrong passwords for this snippet we don't check any more until
+	// some of them have expired, even if the password is right.
+	if !app.unlockLimiter.Reserve(snippet.ID) {
+		form.AddNonFieldErrors("Too many incorrect passwords. Please try again later.")
+
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusTooManyRequests, "unlock.tmpl", data)
+		return
+	}
+
+	// Only wrong passwords count towards the limit, so anything else gives
+	// the attempt back.
+	err = app.snippets.Unlock(snippet.ID, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddNonFieldErrors("Password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Snippet = snippet
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "unlock.tmpl", data)
+		} else if errors.Is(err, models.ErrNoRecord) {
+			app.unlockLimiter.Refund(snippet.ID)
+			app.notFound(w, r)
+		} else {
+			app.unlockLimiter.Refund(snippet.ID)
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.unlockLimiter.Refund(snippet.ID)
+	app.rememberUnlock(r, snippet.ID)
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// The snippetRaw handler sends the content of a snippet exactly as it was
+// saved, as plain text. This is the only way to copy a snippet without the
+// browser changing tabs or trailing whitespace, which matters for things like
+// Makefiles.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	app.sendSnippetContent(w, r, false)
+}
+
+// The snippetDownload handler is the same as snippetRaw, except that it tells
+// the browser to save the content as a file instead of showing it.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	app.sendSnippetContent(w, r, true)
+}
+
+// The sendSnippetContent() helper does the work for the snippetRaw and
+// snippetDownload handlers. It follows the same rules as the view page, so
+// burn-after-reading snippets are deleted once they've been sent, and users
+// who haven't unlocked a protected snippet are sent to the unlock form. If
+// the route has a name parameter we send that one of the snippet's extra
+// files instead of its main content.
+func (app *application) sendSnippetContent(w http.ResponseWriter, r *http.Request, attachment bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, locked, err := app.viewSnippet(r, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	if locked {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+		return
+	}
+
+	content, filename := snippet.Content, snippetFilename(snippet)
+
+	if name := httprouter.ParamsFromContext(r.Context()).ByName("name"); name != "" {
+		file := findFile(snippet, name)
+		if file == nil {
+			app.notFound(w, r)
+			return
+		}
+		content, filename = file.Content, file.Name
+	}
+
+	// The secureHeaders middleware already sets "X-Content-Type-Options:
+	// nosniff", but we set it again here because it's what stops a browser
+	// from running a snippet full of HTML as a web page, and we don't want
+	// that to depend on how the routes happen to be wired up.
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// The mime.FormatMediaType() function takes care of quoting the filename.
+	if attachment {
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+		w.Header().Set("Content-Disposition", disposition)
+	}
+
+	w.Write([]byte(content))
+}
+
+// The snippetHistory handler lists the earlier versions of a snippet, which
+// are recorded each time the snippet is edited.
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
 		return
 	}
 
-	// Use the SnippetModel object's Get method to retrieve the data for a
-	// specific record based on its ID. If no matching record is found,
-	// return a 404 Not Found response.
-	snippet, err := app.snippets.Get(id)
+	snippet, err := app.snippets.Get(id, app.authenticatedUserID(r))
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
+			app.notFound(w, r)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
-	// And do the same thing again here...
+	// Burn-after-reading snippets can only be read through the view page,
+	// so we pretend they don't exist here.
+	if snippet.BurnAfterReading {
+		app.notFound(w, r)
+		return
+	}
+
+	// Earlier versions of a protected snippet are protected by the same
+	// password, so send the user to the unlock form if they need it.
+	if app.isLocked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.Revisions = revisions
 
-	// Use the new render helper.
-	app.render(w, http.StatusOK, "view.tmpl", data)
+	app.render(w, r, http.StatusOK, "history.tmpl", data)
+}
+
+// The snippetDiff handler shows a unified diff between two versions of a
+// snippet. The "from" query string parameter is required and must be the ID
+// of one of the snippet's revisions. The "to" parameter is optional, and if
+// it's missing we compare against the current version of the snippet.
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Burn-after-reading snippets can only be read through the view page,
+	// so we pretend they don't exist here.
+	if snippet.BurnAfterReading {
+		app.notFound(w, r)
+		return
+	}
+
+	// Earlier versions of a protected snippet are protected by the same
+	// password, so send the user to the unlock form if they need it.
+	if app.isLocked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+		return
+	}
+
+	// Both revision IDs must be positive integers. A missing or malformed
+	// "from" value is a bad request, while a revision which doesn't belong
+	// to this snippet is treated as not found.
+	fromID, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil || fromID < 1 {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	from, err := app.snippets.Revision(snippet.ID, fromID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	var to *models.Revision
+
+	if value := r.URL.Query().Get("to"); value != "" {
+		toID, err := strconv.Atoi(value)
+		if err != nil || toID < 1 {
+			app.clientError(w, r, http.StatusBadRequest)
+			return
+		}
+
+		to, err = app.snippets.Revision(snippet.ID, toID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+	}
+
+	toContent := snippet.Content
+	if to != nil {
+		toContent = to.Content
+	}
+
+	// Very long versions would take too long to compare, so for those we
+	// just say that the diff is too large instead.
+	hunks, err := diff.Unified(from.Content, toContent, 3)
+	if err != nil && !errors.Is(err, diff.ErrTooLarge) {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.FromRevision = from
+	data.ToRevision = to
+	data.Hunks = hunks
+	data.DiffTooLarge = errors.Is(err, diff.ErrTooLarge)
+
+	app.render(w, r, http.StatusOK, "diff.tmpl", data)
+}
+
+// The snippetSearch handler runs a full-text search over the titles and
+// content of all the current snippets. The search query is read from the "q"
+// query string parameter and the page number from "page".
+func (app *application) snippetSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	// Treat a missing or malformed page number as the first page.
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	data := app.newTemplateData(r)
+	data.Query = query
+
+	// Only hit the database if the user has actually searched for something.
+	// Otherwise we just show the empty search form.
+	if query != "" {
+		snippets, metadata, err := app.snippets.Search(query, page)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data.Snippets = snippets
+		data.Metadata = metadata
+	}
+
+	app.render(w, r, http.StatusOK, "search.tmpl", data)
+}
+
+// The snippetTag handler lists the public snippets with a given tag, with the
+// same paging and sorting options as the home page.
+func (app *application) snippetTag(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	tag := params.ByName("name")
+	if !validator.Matches(tag, models.TagRX) || !validator.MaxChars(tag, models.MaxTagLength) {
+		app.notFound(w, r)
+		return
+	}
+
+	v := validator.Validator{}
+	qs := r.URL.Query()
+
+	filters := models.Filters{
+		Page:     app.readInt(qs, "page", 1, &v),
+		PageSize: app.readInt(qs, "page_size", 10, &v),
+		Sort:     app.readString(qs, "sort", "-created"),
+		Tag:      tag,
+	}
+
+	if models.ValidateFilters(&v, filters); !v.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.List(filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Filters = filters
+	data.Metadata = metadata
+
+	app.render(w, r, http.StatusOK, "tag.tmpl", data)
+}
+
+// The userProfile handler shows a user's name and join date, along with a
+// paginated list of their public snippets. Unlisted and private snippets are
+// never shown here, even to their owner.
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	user, err := app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	v := validator.Validator{}
+	qs := r.URL.Query()
+
+	filters := models.Filters{
+		Page:     app.readInt(qs, "page", 1, &v),
+		PageSize: app.readInt(qs, "page_size", 10, &v),
+		Sort:     app.readString(qs, "sort", "-created"),
+		UserID:   user.ID,
+	}
+
+	if models.ValidateFilters(&v, filters); !v.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	snippets, metadata, err := app.snippets.List(filters)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+	data.Snippets = snippets
+	data.Filters = filters
+	data.Metadata = metadata
+
+	app.render(w, r, http.StatusOK, "profile.tmpl", data)
 }
 
 // Define a snippetCreateForm struct to represent the form data and validation
@@ -88,11 +535,192 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 // example, here we're telling the decoder to store the value form the HTML form
 // input with the name "title" in the Title field. The struct tag `form:"-"`
 // tells the decoder to completely ignore a field duringdecoring.
+// The json struct tags are used when the same struct is decoded from a JSON
+// request body by the API, so that both routes share one set of validation
+// checks.
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
-	validator.Validator `form:"-"`
+	Title               string            `form:"title" json:"title"`
+	Content             string            `form:"content" json:"content"`
+	Language            string            `form:"language" json:"language"`
+	Visibility          string            `form:"visibility" json:"visibility"`
+	Expires             string            `form:"expires" json:"expires"`
+	ExpiresCustom       string            `form:"expires_custom" json:"-"`
+	BurnAfterReading    bool              `form:"burn_after_reading" json:"burn_after_reading"`
+	Password            string            `form:"password" json:"password"`
+	Files               []snippetFileForm `form:"files" json:"files"`
+	Tags                string            `form:"tags" json:"tags"`
+	ForkedFrom          int               `form:"forked_from" json:"forked_from"`
+	validator.Validator `form:"-" json:"-"`
+}
+
+// The snippetFileForm struct holds one of the extra files on the create form.
+// The form fields are named like "files[0].name", which the form decoder
+// turns into a slice.
+type snippetFileForm struct {
+	Name     string `form:"name" json:"name"`
+	Language string `form:"language" json:"language"`
+	Content  string `form:"content" json:"content"`
+}
+
+// The validate() method runs the validation checks for a new snippet, adding
+// any problems to the form's embedded Validator, and returns the expiry time
+// which the Expires field asks for. It's used by the HTML form, the JSON API
+// and the plain-text paste endpoint, so the rules (and the error messages)
+// are the same for all of them.
+func (form *snippetCreateForm) validate(now time.Time) time.Time {
+	// Because teh Validator type is embedded by the snippetCreateForm struct,
+	// we can call CheckField() directly on it to execute our validation checks.
+	// CheckField() will add the provided key and error message to the
+	// FieldErrors map if the check does not evaluate to true. The checks on
+	// the title, content, language and visibility are shared with the edit
+	// form, so they live in checkSnippetFields().
+	checkSnippetFields(&form.Validator, form.Title, form.Content, form.Language, form.Visibility)
+
+	// The expires field holds one of the preset options from the form, or
+	// "custom" in which case the user has typed their own value into the
+	// expires_custom field. Either way it must parse as a duration, a date
+	// and time in the future, or "never".
+	expiry := form.Expires
+	if expiry == "custom" {
+		expiry = strings.TrimSpace(form.ExpiresCustom)
+	}
+
+	expires, err := parseExpiry(expiry, now)
+	form.CheckField(err == nil, "expires", "This field must be a duration like 10m, 3h or 30d, a date and time, or never")
+	form.CheckField(err != nil || expires.IsZero() || expires.After(now), "expires", "This field must be in the future")
+
+	// The password is optional, but bcrypt only uses the first 72 bytes of
+	// it, so we don't accept anything longer.
+	form.CheckField(len(form.Password) <= 72, "password", "This field cannot be more than 72 bytes long")
+
+	// Tags are entered as comma-separated text like "billing, auth".
+	tags := models.ParseTags(form.Tags)
+	form.CheckField(len(tags) <= models.MaxTags, "tags", fmt.Sprintf("A snippet cannot have more than %d tags", models.MaxTags))
+	for _, tag := range tags {
+		form.CheckField(validator.MaxChars(tag, models.MaxTagLength), "tags", fmt.Sprintf("Each tag cannot be more than %d characters long", models.MaxTagLength))
+		form.CheckField(validator.Matches(tag, models.TagRX), "tags", "Tags can only contain letters, digits and dashes")
+	}
+
+	form.validateFiles()
+
+	return expires
+}
+
+// The checkSnippetFields() function runs the checks on the fields which a
+// snippet has both when it's created and when it's edited, so that an edit
+// can't store anything which creating the snippet would have refused.
+func checkSnippetFields(v *validator.Validator, title, content, language, visibility string) {
+	v.CheckField(validator.NotBlank(title), "title", "This field cannot be blank")
+	v.CheckField(validator.MaxChars(title, 100), "title", "This field cannot be more than 100 characters long")
+	v.CheckField(validator.NotBlank(content), "content", "This field must cannot be blank")
+	v.CheckField(utf8.ValidString(content), "content", "This field must be valid UTF-8 text")
+	v.CheckField(validator.PermittedValue(language, highlight.Languages...), "language", "This field must be one of the listed languages")
+	v.CheckField(validator.PermittedValue(visibility, models.Visibilities...), "visibility", "This field must equal public, unlisted or private")
+}
+
+// The validateFiles() method checks the extra files. A file which the user
+// added to the form but left completely empty is dropped rather than
+// reported, and errors are keyed like "files[0].name" so that the template can
+// show them next to the right field.
+func (form *snippetCreateForm) validateFiles() {
+	files := []snippetFileForm{}
+	for _, f := range form.Files {
+		if strings.TrimSpace(f.Name) != "" || strings.TrimSpace(f.Content) != "" {
+			if f.Language == "" {
+				f.Language = "plaintext"
+			}
+			files = append(files, f)
+		}
+	}
+	form.Files = files
+
+	form.CheckField(len(form.Files) <= models.MaxFiles, "files", fmt.Sprintf("A snippet cannot have more than %d extra files", models.MaxFiles))
+
+	seen := make(map[string]bool)
+
+	for i, f := range form.Files {
+		key := fmt.Sprintf("files[%d].", i)
+
+		form.CheckField(validator.NotBlank(f.Name), key+"name", "This field cannot be blank")
+		form.CheckField(validator.MaxChars(f.Name, 100), key+"name", "This field cannot be more than 100 characters long")
+		form.CheckField(validator.Matches(f.Name, validator.FilenameRX), key+"name", "This field can only contain letters, digits, dots, dashes and underscores")
+		form.CheckField(f.Name != "." && f.Name != "..", key+"name", "This field must be a file name")
+		form.CheckField(!seen[f.Name], key+"name", "Each file must have a different name")
+		form.CheckField(validator.NotBlank(f.Content), key+"content", "This field cannot be blank")
+		form.CheckField(utf8.ValidString(f.Content), key+"content", "This field must be valid UTF-8 text")
+		form.CheckField(validator.PermittedValue(f.Language, highlight.Languages...), key+"language", "This field must be one of the listed languages")
+
+		seen[f.Name] = true
+	}
+}
+
+// The snippet() method returns a new snippet, ready to be inserted, from the
+// validated form data.
+func (form *snippetCreateForm) snippet(expires time.Time, userID int) *models.Snippet {
+	files := make([]*models.File, 0, len(form.Files))
+	for _, f := range form.Files {
+		files = append(files, &models.File{Name: f.Name, Language: f.Language, Content: f.Content})
+	}
+
+	return &models.Snippet{
+		Title:            form.Title,
+		Content:          form.Content,
+		Language:         form.Language,
+		Visibility:       form.Visibility,
+		Expires:          expires,
+		BurnAfterReading: form.BurnAfterReading,
+		Password:         form.Password,
+		UserID:           userID,
+		Files:            files,
+		ForkedFrom:       form.ForkedFrom,
+		Tags:             models.ParseTags(form.Tags),
+	}
+}
+
+// The forkSource() method fetches the snippet with the given ID so that the
+// current user can fork it. Snippets which the user can't see, and
+// burn-after-reading snippets (which would be deleted by reading them), can't
+// be forked, so for those we return ErrNoRecord. The caller must check
+// whether the snippet is locked.
+func (app *application) forkSource(r *http.Request, id int) (*models.Snippet, error) {
+	if id < 1 {
+		return nil, models.ErrNoRecord
+	}
+
+	snippet, err := app.snippets.Get(id, app.authenticatedUserID(r))
+	if err != nil {
+		return nil, err
+	}
+
+	if snippet.BurnAfterReading {
+		return nil, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+// The checkFork() method clears the form's ForkedFrom field if the user isn't
+// allowed to fork that snippet, or it has gone since the form was shown. The
+// new snippet is still created, just without the link to the original.
+func (app *application) checkFork(r *http.Request, form *snippetCreateForm) error {
+	if form.ForkedFrom == 0 {
+		return nil
+	}
+
+	source, err := app.forkSource(r, form.ForkedFrom)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			form.ForkedFrom = 0
+			return nil
+		}
+		return err
+	}
+
+	if app.isLocked(r, source) {
+		form.ForkedFrom = 0
+	}
+
+	return nil
 }
 
 // Add a new snippetCreate handler, which for now returns a placeholder
@@ -104,11 +732,63 @@ func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	// Notice how this is also a great opportunity to set any default or
 	// 'initial' values for the form --- here we set the initial value for the
 	// snippet expiry to 365 days.
-	data.Form = snippetCreateForm{
-		Expires: 365,
+	form := snippetCreateForm{
+		Language:   "plaintext",
+		Visibility: models.VisibilityPublic,
+		Expires:    "365d",
+	}
+
+	// If there's a "fork" query string parameter, pre-fill the form with a
+	// copy of that snippet. The password isn't copied, because we only have
+	// its hash.
+	if fork := r.URL.Query().Get("fork"); fork != "" {
+		id, err := strconv.Atoi(fork)
+		if err != nil {
+			app.notFound(w, r)
+			return
+		}
+
+		source, err := app.forkSource(r, id)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		if app.isLocked(r, source) {
+			http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", source.ID), http.StatusSeeOther)
+			return
+		}
+
+		form.Title = source.Title
+		form.Content = source.Content
+		form.Language = source.Language
+		form.Visibility = source.Visibility
+		form.ForkedFrom = source.ID
+		form.Tags = strings.Join(source.Tags, ", ")
+
+		for _, f := range source.Files {
+			form.Files = append(form.Files, snippetFileForm{Name: f.Name, Language: f.Language, Content: f.Content})
+		}
 	}
 
-	app.render(w, http.StatusOK, "create.tmpl", data)
+	// Users who haven't confirmed their email address can't create public
+	// snippets, so start them off with an unlisted one instead.
+	if form.Visibility == models.VisibilityPublic {
+		visibility, err := app.defaultVisibility(r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		form.Visibility = visibility
+	}
+
+	data.Form = form
+
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
 }
 
 // Change the signature of the snippetCreate handler so it is defined as a method
@@ -125,24 +805,24 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	// If there is a problem, we return a 400 Bad Request response to the client.
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
-	// Because teh Validator type is embedded by the snippetCreateForm struct,
-	// we can call CheckField() directly on it to execute our validation checks.
-	// CheckField() will add the provided key and error message to the
-	// FieldErrors map if the check does not evaluate to true. For example, in
-	// the first line here we "check that the form.Title field is not blank". In
-	// the second, we "check that the field has a maximum character
-	// length of 100" and so on.
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field must cannot be blank")
-
-	// Use the generic ParmittedValue() function instead of the type-specific
-	// PermittedInt function.
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	// Run the validation checks, which also work out the expiry time.
+	expires := form.validate(time.Now())
+
+	err = app.checkFork(r, &form)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.checkVisibility(r, &form.Validator, form.Visibility)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
 	// If there are any validation errors re-display the create.tmpl template,
 	// passing in the snippetCreateForm instance as dynamic data in the Form
@@ -155,15 +835,25 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
 		return
 	}
 
 	// We also need to update this line to pass the data from the
-	// snippetCreateForm instance to our Insert() method.
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// snippetCreateForm instance to our Insert() method, along with the ID of
+	// the authenticated user so that the snippet is recorded as theirs.
+	id, err := app.snippets.Insert(form.snippet(expires, app.authenticatedUserID(r)))
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
+		return
+	}
+
+	// If we redirected to a burn-after-reading snippet it would be deleted
+	// straight away, so instead we give the user the link to pass on and send
+	// them back to the home page.
+	if form.BurnAfterReading {
+		app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet successfully created! It can be viewed once at /snippet/view/%d", id))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
@@ -175,6 +865,93 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
 }
 
+// The snippetEditForm struct holds the form data and validation errors for
+// the edit snippet form. The expiry time can't be changed once a snippet has
+// been created, so there's no Expires field here.
+type snippetEditForm struct {
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	Language            string `form:"language"`
+	Visibility          string `form:"visibility"`
+	validator.Validator `form:"-"`
+}
+
+// The snippetEdit handler displays the edit form, pre-populated with the
+// current title, content, language and visibility of the snippet.
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet := app.contextSnippet(r)
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:      snippet.Title,
+		Content:    snippet.Content,
+		Language:   snippet.Language,
+		Visibility: snippet.Visibility,
+	}
+
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.contextSnippet(r)
+
+	var form snippetEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// Apply the same title and content checks that we use when a snippet is
+	// first created.
+	checkSnippetFields(&form.Validator, form.Title, form.Content, form.Language, form.Visibility)
+
+	// Snippets which are already public can stay that way, but unverified
+	// users can't make any others public.
+	if snippet.Visibility != models.VisibilityPublic {
+		err = app.checkVisibility(r, &form.Validator, form.Visibility)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content, form.Language, form.Visibility)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.contextSnippet(r)
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted!")
+
+	// The snippet no longer exists, so send the user back to the home page.
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 // Create a new userSignupForm struct.
 type userSignupForm struct {
 	Name                string `form:"name"`
@@ -187,7 +964,7 @@ type userSignupForm struct {
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
-	app.render(w, http.StatusOK, "signup.tmpl", data)
+	app.render(w, r, http.StatusOK, "signup.tmpl", data)
 }
 
 func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
@@ -197,7 +974,7 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	// Parse the form data into the userSignupForm struct.
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -213,29 +990,37 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		return
 	}
 
 	// Try to create a new user record in the database. If the email already
 	// exists then add an error message to the form and re-display it.
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	id, err := app.users.Insert(form.Name, form.Email, form.Password)
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.AddFieldError("email", "Email address is already in use")
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "signup.tmpl", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	// New accounts start off unverified, so email the user a link to confirm
+	// their address.
+	err = app.sendVerificationEmail(id, form.Name, form.Email)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Otherwise add a confirmation flash message to the session confirming that
 	// their signup worked.
-	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. We've emailed you a link to confirm your address. Please log in.")
 
 	// And redirect the user to the login page.
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
@@ -252,7 +1037,7 @@ type userLoginForm struct {
 func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userLoginForm{}
-	app.render(w, http.StatusOK, "login.tmpl", data)
+	app.render(w, r, http.StatusOK, "login.tmpl", data)
 }
 
 func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
@@ -261,7 +1046,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
-		app.clientError(w, http.StatusBadRequest)
+		app.clientError(w, r, http.StatusBadRequest)
 		return
 	}
 
@@ -275,7 +1060,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
 		return
 	}
 
@@ -288,9 +1073,9 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 
 			data := app.newTemplateData(r)
 			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
 		} else {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 		}
 		return
 	}
@@ -301,7 +1086,7 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 	// and logout operation).
 	err = app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -319,7 +1104,7 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	// ID again.
 	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -339,3 +1124,213 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
+
+// The accountTokenForm struct holds the form data for creating a new personal
+// API token. The expires field takes the same values as the snippet expiry.
+type accountTokenForm struct {
+	Name                string `form:"name"`
+	Expires             string `form:"expires"`
+	validator.Validator `form:"-"`
+}
+
+// The accountTokens handler lists the current user's API tokens, along with
+// a form for creating a new one.
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := app.tokens.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tokens = tokens
+	data.Form = accountTokenForm{Expires: "90d"}
+
+	app.render(w, r, http.StatusOK, "tokens.tmpl", data)
+}
+
+// The accountTokensPost handler creates a new API token. We don't store the
+// plain-text token anywhere, so rather than redirecting we render the page
+// straight away with the token shown once.
+func (app *application) accountTokensPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+
+	var form accountTokenForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	expires, err := parseExpiry(form.Expires, now)
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(err == nil, "expires", "This field must be a duration like 30d, a date and time, or never")
+	form.CheckField(err != nil || expires.IsZero() || expires.After(now), "expires", "This field must be in the future")
+
+	if !form.Valid() {
+		tokens, err := app.tokens.ForUser(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data := app.newTemplateData(r)
+		data.Tokens = tokens
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "tokens.tmpl", data)
+		return
+	}
+
+	plaintext, err := app.tokens.Insert(userID, form.Name, expires)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	tokens, err := app.tokens.ForUser(userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tokens = tokens
+	data.NewToken = plaintext
+	data.Form = accountTokenForm{Expires: "90d"}
+
+	app.render(w, r, http.StatusOK, "tokens.tmpl", data)
+}
+
+// The accountTokenDeletePost handler revokes one of the current user's API
+// tokens. Tokens belonging to other users are reported as not found.
+func (app *application) accountTokenDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return
+	}
+
+	err = app.tokens.Delete(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token successfully revoked!")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
+// The accountView handler shows the current user's name, email address and
+// signup date.
+func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		// If the user has been deleted since they logged in, send them back
+		// to the login page.
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.User = user
+
+	app.render(w, r, http.StatusOK, "account.tmpl", data)
+}
+
+// Create a new accountPasswordUpdateForm struct. The new password has to be
+// typed twice, so that a typo doesn't lock the user out of their account. If
+// RevokeTokens is set, all of the user's API tokens are revoked too.
+type accountPasswordUpdateForm struct {
+	CurrentPassword         string `form:"current_password"`
+	NewPassword             string `form:"new_password"`
+	NewPasswordConfirmation string `form:"new_password_confirmation"`
+	RevokeTokens            bool   `form:"revoke_tokens"`
+	validator.Validator     `form:"-"`
+}
+
+// The accountPasswordUpdate handler displays the change password form.
+func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountPasswordUpdateForm{}
+
+	app.render(w, r, http.StatusOK, "password.tmpl", data)
+}
+
+func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http.Request) {
+	var form accountPasswordUpdateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "current_password", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.NewPassword), "new_password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "new_password", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "new_password_confirmation", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "new_password_confirmation", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl", data)
+		return
+	}
+
+	// The password is only changed if the current password is correct, so
+	// that someone who finds a logged-in browser can't lock the owner out.
+	err = app.users.PasswordUpdate(app.authenticatedUserID(r), form.CurrentPassword, form.NewPassword)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddFieldError("current_password", "Current password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "password.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Change the session ID, in the same way as when the user logs in, so
+	// that a session token captured before the change can't be reused, and
+	// log the user out everywhere else.
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.destroyOtherSessions(r, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if form.RevokeTokens {
+		err = app.tokens.DeleteAllForUser(app.authenticatedUserID(r))
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been updated!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
+	"strings"
 	"testing"
 
 	"snippetbox.example.org/internal/assert"
@@ -46,6 +48,65 @@ func TestSnippetView(t *testing.T) {
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
+		{
+			name:     "Language label",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<code class='language-plaintext'>An old silent pond...</code>",
+		},
+		{
+			name:     "Burn after reading",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusOK,
+			wantBody: "This snippet has now been deleted.",
+		},
+		{
+			name:     "Never expires",
+			urlPath:  "/snippet/view/3",
+			wantCode: http.StatusOK,
+			wantBody: "Expires: Never",
+		},
+		{
+			name:     "Extra file",
+			urlPath:  "/snippet/view/3",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/raw/3/notes.txt'>Raw</a>",
+		},
+		{
+			name:     "Forked from",
+			urlPath:  "/snippet/view/3",
+			wantCode: http.StatusOK,
+			wantBody: "<span>Forked from <a href='/snippet/view/1'>#1</a></span>",
+		},
+		{
+			name:     "Fork count",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<span>1 fork</span>",
+		},
+		{
+			name:     "Tags",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "<a class='tag' href='/tag/billing'>billing</a> <a class='tag' href='/tag/infra'>infra</a>",
+		},
+		{
+			name:     "Author name",
+			urlPath:  "/snippet/view/1",
+			wantCode: http.StatusOK,
+			wantBody: "by <a href='/user/profile/1'>Alice Jones</a>",
+		},
+		{
+			name:     "Unlisted",
+			urlPath:  "/snippet/view/5",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='visibility'>unlisted</span>",
+		},
+		{
+			name:     "Private",
+			urlPath:  "/snippet/view/6",
+			wantCode: http.StatusNotFound,
+		},
 		{
 			name:     "Non-existent ID",
 			urlPath:  "/snippet/view/2",
@@ -86,6 +147,170 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
+func TestSnippetViewPrivate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Snippet 6 is private and belongs to Alice, so it should be hidden from
+	// anonymous visitors but shown to her once she has logged in.
+	code, _, _ := ts.get(t, "/snippet/view/6")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/view/6")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Rice, tea, plum blossom")
+	assert.StringContains(t, body, "<span class='visibility'>private</span>")
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantBody        string
+		wantDisposition string
+		wantLocation    string
+	}{
+		{
+			name:     "Raw",
+			urlPath:  "/snippet/raw/1",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:            "Download",
+			urlPath:         "/snippet/download/1",
+			wantCode:        http.StatusOK,
+			wantBody:        "An old silent pond...",
+			wantDisposition: "attachment; filename=an-old-silent-pond.txt",
+		},
+		{
+			name:     "Private",
+			urlPath:  "/snippet/raw/6",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:         "Protected",
+			urlPath:      "/snippet/download/7",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/7",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/raw/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Extra file",
+			urlPath:  "/snippet/raw/3/notes.txt",
+			wantCode: http.StatusOK,
+			wantBody: "with no leaves to blow.",
+		},
+		{
+			name:            "Download extra file",
+			urlPath:         "/snippet/download/3/notes.txt",
+			wantCode:        http.StatusOK,
+			wantBody:        "with no leaves to blow.",
+			wantDisposition: "attachment; filename=notes.txt",
+		},
+		{
+			name:     "Non-existent file",
+			urlPath:  "/snippet/raw/3/go.mod",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, body, tt.wantBody)
+				assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+				assert.Equal(t, headers.Get("X-Content-Type-Options"), "nosniff")
+				assert.Equal(t, headers.Get("Content-Disposition"), tt.wantDisposition)
+			}
+
+			if tt.wantLocation != "" {
+				assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+			}
+		})
+	}
+}
+
+func TestSnippetUnlock(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Snippet 7 is protected, so the view page should show the unlock form
+	// rather than the content.
+	code, _, body := ts.get(t, "/snippet/view/7")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<form action='/snippet/unlock/7' method='POST' novalidate>")
+	assert.Equal(t, strings.Contains(body, "The network is called basho"), false)
+
+	// The history page is protected by the same password.
+	code, headers, _ := ts.get(t, "/snippet/view/7/history")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/7")
+
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("password", "wrong")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, body = ts.postForm(t, "/snippet/unlock/7", form)
+	assert.Equal(t, code, http.StatusUnprocessableEntity)
+	assert.StringContains(t, body, "Password is incorrect")
+
+	form.Set("password", "letmein")
+
+	code, headers, _ = ts.postForm(t, "/snippet/unlock/7", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/snippet/view/7")
+
+	// The unlock is remembered in the session.
+	code, _, body = ts.get(t, "/snippet/view/7")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "The network is called basho")
+}
+
+func TestSnippetUnlockRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/view/7")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("password", "wrong")
+	form.Add("csrf_token", csrfToken)
+
+	for i := 0; i < 5; i++ {
+		code, _, _ := ts.postForm(t, "/snippet/unlock/7", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	// Once the limit has been reached even the right password is refused.
+	form.Set("password", "letmein")
+
+	code, _, body := ts.postForm(t, "/snippet/unlock/7", form)
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "Too many incorrect passwords")
+}
+
 func TestUserSignup(t *testing.T) {
 	// Create the application struct containing our mocked dependencies and set
 	// up the test server for running and end-to-end test.
@@ -209,3 +434,1258 @@ func TestUserSignup(t *testing.T) {
 		})
 	}
 }
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Unauthenticated users should be redirected to the login page.
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/edit/1")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Owner",
+			urlPath:  "/snippet/edit/1",
+			wantCode: http.StatusOK,
+			wantBody: "<form action='/snippet/edit/1' method='POST'>",
+		},
+		{
+			name:     "Not owner",
+			urlPath:  "/snippet/edit/3",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/edit/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/snippet/edit/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetEditPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		title        string
+		content      string
+		language     string
+		visibility   string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid submission",
+			urlPath:      "/snippet/edit/1",
+			title:        "An old silent pond",
+			content:      "A frog jumps into the pond",
+			language:     "go",
+			visibility:   "public",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:       "Empty title",
+			urlPath:    "/snippet/edit/1",
+			title:      "",
+			content:    "A frog jumps into the pond",
+			language:   "plaintext",
+			visibility: "public",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Unknown language",
+			urlPath:    "/snippet/edit/1",
+			title:      "An old silent pond",
+			content:    "A frog jumps into the pond",
+			language:   "cobol",
+			visibility: "public",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Not owner",
+			urlPath:    "/snippet/edit/3",
+			title:      "Over the wintry forest",
+			content:    "Mine now",
+			language:   "plaintext",
+			visibility: "public",
+			wantCode:   http.StatusForbidden,
+		},
+		{
+			name:       "Invalid UTF-8 content",
+			urlPath:    "/snippet/edit/1",
+			title:      "An old silent pond",
+			content:    "A frog jumps \xff into the pond",
+			language:   "plaintext",
+			visibility: "public",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Unknown visibility",
+			urlPath:    "/snippet/edit/1",
+			title:      "An old silent pond",
+			content:    "A frog jumps into the pond",
+			language:   "plaintext",
+			visibility: "secret",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("language", tt.language)
+			form.Add("visibility", tt.visibility)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetDeletePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Owner",
+			urlPath:      "/snippet/delete/1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/",
+		},
+		{
+			name:     "Not owner",
+			urlPath:  "/snippet/delete/3",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/delete/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid ID",
+			urlPath:  "/snippet/view/1/history",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/1/diff?from=1'>An old silent pond</a>",
+		},
+		{
+			name:     "No revisions",
+			urlPath:  "/snippet/view/3/history",
+			wantCode: http.StatusOK,
+			wantBody: "This snippet hasn't been edited",
+		},
+		{
+			name:     "Burn after reading",
+			urlPath:  "/snippet/view/4/history",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/view/2/history",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetDiff(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Against current version",
+			urlPath:  "/snippet/view/1/diff?from=1",
+			wantCode: http.StatusOK,
+			wantBody: "<span class='op-insert'>&#43;An old silent pond...</span>",
+		},
+		{
+			name:     "Between revisions",
+			urlPath:  "/snippet/view/1/diff?from=1&to=1",
+			wantCode: http.StatusOK,
+			wantBody: "These versions are identical.",
+		},
+		{
+			name:     "Missing from",
+			urlPath:  "/snippet/view/1/diff",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid to",
+			urlPath:  "/snippet/view/1/diff?from=1&to=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Revision of another snippet",
+			urlPath:  "/snippet/view/3/diff?from=1",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody string
+	}{
+		{
+			name:     "Empty query",
+			urlPath:  "/snippet/search",
+			wantBody: "<form action='/snippet/search' method='GET' class='search'>",
+		},
+		{
+			name:     "Matching query",
+			urlPath:  "/snippet/search?q=pond",
+			wantBody: "<a href='/snippet/view/1'>An old silent <mark>pond</mark></a>",
+		},
+		{
+			name:     "Pagination",
+			urlPath:  "/snippet/search?q=pond",
+			wantBody: "Page 1 of 1",
+		},
+		{
+			name:     "No matches",
+			urlPath:  "/snippet/search?q=frog",
+			wantBody: `No snippets matched "frog".`,
+		},
+		{
+			name:     "Invalid page",
+			urlPath:  "/snippet/search?q=pond&page=foo",
+			wantBody: "An old silent <mark>pond</mark>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		wantCode  int
+		wantBody  []string
+		wantNotIn string
+	}{
+		{
+			name:     "Defaults",
+			urlPath:  "/",
+			wantCode: http.StatusOK,
+			wantBody: []string{"An old silent pond", "Over the wintry forest", "Page 1 of 1"},
+		},
+		{
+			name:      "Unlisted snippets are hidden",
+			urlPath:   "/",
+			wantCode:  http.StatusOK,
+			wantBody:  []string{"An old silent pond"},
+			wantNotIn: "First autumn morning",
+		},
+		{
+			name:      "First page",
+			urlPath:   "/?page_size=1&sort=title",
+			wantCode:  http.StatusOK,
+			wantBody:  []string{"An old silent pond", "Page 1 of 2", "page=2'>Next"},
+			wantNotIn: "Over the wintry forest",
+		},
+		{
+			name:      "Last page",
+			urlPath:   "/?page_size=1&sort=title&page=2",
+			wantCode:  http.StatusOK,
+			wantBody:  []string{"Over the wintry forest", "Page 2 of 2", "page=1'>&larr; Previous"},
+			wantNotIn: "An old silent pond",
+		},
+		{
+			name:     "Beyond the last page",
+			urlPath:  "/?page=5",
+			wantCode: http.StatusOK,
+			wantBody: []string{"There are no snippets on this page."},
+		},
+		{
+			name:      "Descending sort",
+			urlPath:   "/?page_size=1&sort=-title",
+			wantCode:  http.StatusOK,
+			wantBody:  []string{"Over the wintry forest"},
+			wantNotIn: "An old silent pond",
+		},
+		{
+			name:     "Invalid sort",
+			urlPath:  "/?sort=content",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid page",
+			urlPath:  "/?page=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Page size too large",
+			urlPath:  "/?page_size=1000",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+
+			if tt.wantNotIn != "" {
+				assert.Equal(t, strings.Contains(body, tt.wantNotIn), false)
+			}
+		})
+	}
+}
+
+func TestSnippetCreatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	const (
+		validTitle   = "A frog"
+		validContent = "package main"
+		formTag      = "<form action='/snippet/create' method='POST'>"
+	)
+
+	tests := []struct {
+		name         string
+		title        string
+		content      string
+		language     string
+		visibility   string
+		expires      string
+		custom       string
+		burn         bool
+		password     string
+		wantCode     int
+		wantLocation string
+		wantFormTag  string
+	}{
+		{
+			name:         "Valid submission",
+			title:        validTitle,
+			content:      validContent,
+			language:     "go",
+			visibility:   "public",
+			expires:      "7d",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:         "Burn after reading",
+			title:        validTitle,
+			content:      validContent,
+			language:     "go",
+			visibility:   "public",
+			expires:      "7d",
+			burn:         true,
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/",
+		},
+		{
+			name:         "Never expires",
+			title:        validTitle,
+			content:      validContent,
+			language:     "go",
+			visibility:   "public",
+			expires:      "never",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:         "Custom duration",
+			title:        validTitle,
+			content:      validContent,
+			language:     "go",
+			visibility:   "public",
+			expires:      "custom",
+			custom:       "10m",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:         "Custom date-time",
+			title:        validTitle,
+			content:      validContent,
+			language:     "go",
+			visibility:   "public",
+			expires:      "custom",
+			custom:       "2099-12-31T18:00",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:        "Custom date-time in the past",
+			title:       validTitle,
+			content:     validContent,
+			language:    "go",
+			visibility:  "public",
+			expires:     "custom",
+			custom:      "2001-01-01 00:00",
+			wantCode:    http.StatusUnprocessableEntity,
+			wantFormTag: formTag,
+		},
+		{
+			name:        "Invalid custom expiry",
+			title:       validTitle,
+			content:     validContent,
+			language:    "go",
+			visibility:  "public",
+			expires:     "custom",
+			custom:      "tomorrow",
+			wantCode:    http.StatusUnprocessableEntity,
+			wantFormTag: formTag,
+		},
+		{
+			name:         "With password",
+			title:        validTitle,
+			content:      validContent,
+			language:     "go",
+			visibility:   "unlisted",
+			expires:      "7d",
+			password:     "letmein",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:        "Password too long",
+			title:       validTitle,
+			content:     validContent,
+			language:    "go",
+			visibility:  "unlisted",
+			expires:     "7d",
+			password:    strings.Repeat("a", 73),
+			wantCode:    http.StatusUnprocessableEntity,
+			wantFormTag: formTag,
+		},
+		{
+			name:        "Empty title",
+			title:       "",
+			content:     validContent,
+			language:    "go",
+			visibility:  "public",
+			expires:     "7d",
+			wantCode:    http.StatusUnprocessableEntity,
+			wantFormTag: formTag,
+		},
+		{
+			name:        "Unknown language",
+			title:       validTitle,
+			content:     validContent,
+			language:    "cobol",
+			visibility:  "public",
+			expires:     "7d",
+			wantCode:    http.StatusUnprocessableEntity,
+			wantFormTag: formTag,
+		},
+		{
+			name:         "Private",
+			title:        validTitle,
+			content:      validContent,
+			language:     "go",
+			visibility:   "private",
+			expires:      "7d",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/2",
+		},
+		{
+			name:        "Unknown visibility",
+			title:       validTitle,
+			content:     validContent,
+			language:    "go",
+			visibility:  "friends",
+			expires:     "7d",
+			wantCode:    http.StatusUnprocessableEntity,
+			wantFormTag: formTag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("language", tt.language)
+			form.Add("visibility", tt.visibility)
+			form.Add("expires", tt.expires)
+			form.Add("expires_custom", tt.custom)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+			if tt.burn {
+				form.Add("burn_after_reading", "true")
+			}
+
+			code, headers, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantFormTag != "" {
+				assert.StringContains(t, body, tt.wantFormTag)
+			}
+		})
+	}
+}
+
+func TestAccountTokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The tokens page is only for logged-in users.
+	code, headers, _ := ts.get(t, "/account/tokens")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	csrfToken := ts.login(t)
+
+	code, _, body := ts.get(t, "/account/tokens")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "CI server")
+	assert.StringContains(t, body, "<form action='/account/tokens/delete/1' method='POST'>")
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			tokenName string
+			expires   string
+			wantCode  int
+			wantBody  string
+		}{
+			{
+				name:      "Valid submission",
+				tokenName: "Laptop",
+				expires:   "30d",
+				wantCode:  http.StatusOK,
+				wantBody:  "<code>NEWTOKENNEWTOKENNEWTOKENNEWTOKEN</code>",
+			},
+			{
+				name:      "Never expires",
+				tokenName: "Laptop",
+				expires:   "never",
+				wantCode:  http.StatusOK,
+				wantBody:  "<code>NEWTOKENNEWTOKENNEWTOKENNEWTOKEN</code>",
+			},
+			{
+				name:      "Empty name",
+				tokenName: "",
+				expires:   "30d",
+				wantCode:  http.StatusUnprocessableEntity,
+				wantBody:  "This field cannot be blank",
+			},
+			{
+				name:      "Invalid expiry",
+				tokenName: "Laptop",
+				expires:   "whenever",
+				wantCode:  http.StatusUnprocessableEntity,
+				wantBody:  "This field must be a duration like 30d",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				form := url.Values{}
+				form.Add("name", tt.tokenName)
+				form.Add("expires", tt.expires)
+				form.Add("csrf_token", csrfToken)
+
+				code, _, body := ts.postForm(t, "/account/tokens", form)
+
+				assert.Equal(t, code, tt.wantCode)
+				assert.StringContains(t, body, tt.wantBody)
+			})
+		}
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("csrf_token", csrfToken)
+
+		code, headers, _ := ts.postForm(t, "/account/tokens/delete/1", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/account/tokens")
+
+		code, _, _ = ts.postForm(t, "/account/tokens/delete/2", form)
+		assert.Equal(t, code, http.StatusNotFound)
+	})
+}
+
+func TestSnippetCreatePostFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "Valid files",
+			files: map[string]string{
+				"files[0].name":     "go.mod",
+				"files[0].language": "plaintext",
+				"files[0].content":  "module example.org/frog",
+				"files[1].name":     "frog_test.go",
+				"files[1].language": "go",
+				"files[1].content":  "package main",
+			},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name: "Empty file is ignored",
+			files: map[string]string{
+				"files[0].name":     "",
+				"files[0].language": "plaintext",
+				"files[0].content":  "",
+			},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name: "Missing content",
+			files: map[string]string{
+				"files[0].name":     "go.mod",
+				"files[0].language": "plaintext",
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field cannot be blank",
+		},
+		{
+			name: "Slash in name",
+			files: map[string]string{
+				"files[0].name":     "../go.mod",
+				"files[0].language": "plaintext",
+				"files[0].content":  "module example.org/frog",
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This field can only contain letters, digits, dots, dashes and underscores",
+		},
+		{
+			name: "Duplicate names",
+			files: map[string]string{
+				"files[0].name":     "go.mod",
+				"files[0].language": "plaintext",
+				"files[0].content":  "module example.org/frog",
+				"files[1].name":     "go.mod",
+				"files[1].language": "plaintext",
+				"files[1].content":  "module example.org/toad",
+			},
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Each file must have a different name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "A frog")
+			form.Add("content", "package main")
+			form.Add("language", "go")
+			form.Add("visibility", "public")
+			form.Add("expires", "7d")
+			form.Add("csrf_token", csrfToken)
+			for key, value := range tt.files {
+				form.Add(key, value)
+			}
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// The extra files should be shown on the new snippet's page, each with
+	// its own raw link.
+	form := url.Values{}
+	form.Add("title", "A frog")
+	form.Add("content", "package main")
+	form.Add("language", "go")
+	form.Add("visibility", "public")
+	form.Add("expires", "7d")
+	form.Add("csrf_token", csrfToken)
+	form.Add("files[0].name", "go.mod")
+	form.Add("files[0].language", "plaintext")
+	form.Add("files[0].content", "module example.org/frog")
+
+	code, _, _ := ts.postForm(t, "/snippet/create", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	_, _, body := ts.get(t, "/snippet/view/2")
+	assert.StringContains(t, body, "<a href='/snippet/raw/2/go.mod'>Raw</a>")
+}
+
+func TestSnippetFork(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous users are sent to the login page, as for the create form.
+	code, headers, _ := ts.get(t, "/snippet/create?fork=1")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+		wantBody     []string
+	}{
+		{
+			name:     "Valid fork",
+			urlPath:  "/snippet/create?fork=1",
+			wantCode: http.StatusOK,
+			wantBody: []string{
+				"<input type='hidden' name='forked_from' value='1'>",
+				"<input type='text' name='title' value='An old silent pond'>",
+				"<textarea name='content'>An old silent pond...</textarea>",
+			},
+		},
+		{
+			name:     "Tags are copied",
+			urlPath:  "/snippet/create?fork=1",
+			wantCode: http.StatusOK,
+			wantBody: []string{"<input type='text' name='tags' value='billing, infra' placeholder='billing, auth'>"},
+		},
+		{
+			name:     "Extra files are copied",
+			urlPath:  "/snippet/create?fork=3",
+			wantCode: http.StatusOK,
+			wantBody: []string{"<input type='text' name='files[0].name' value='notes.txt' placeholder='go.mod'>"},
+		},
+		{
+			name:     "Own private snippet",
+			urlPath:  "/snippet/create?fork=6",
+			wantCode: http.StatusOK,
+			wantBody: []string{"Rice, tea, plum blossom"},
+		},
+		{
+			name:         "Protected",
+			urlPath:      "/snippet/create?fork=7",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/7",
+		},
+		{
+			name:     "Burn after reading",
+			urlPath:  "/snippet/create?fork=4",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/create?fork=99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/snippet/create?fork=foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+		})
+	}
+
+	// Creating the fork records where it came from. A reference to a snippet
+	// which can't be forked is dropped.
+	for _, forkedFrom := range []string{"1", "99"} {
+		form := url.Values{}
+		form.Add("title", "An old silent pond")
+		form.Add("content", "An old silent pond... a frog jumps in")
+		form.Add("language", "plaintext")
+		form.Add("visibility", "public")
+		form.Add("expires", "7d")
+		form.Add("forked_from", forkedFrom)
+		form.Add("csrf_token", csrfToken)
+
+		code, _, _ := ts.postForm(t, "/snippet/create", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		_, _, body := ts.get(t, "/snippet/view/2")
+		assert.Equal(t, strings.Contains(body, "Forked from <a href='/snippet/view/1'>#1</a>"), forkedFrom == "1")
+	}
+}
+
+func TestSnippetTag(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    []string
+		notWantBody []string
+	}{
+		{
+			name:     "Shared tag",
+			urlPath:  "/tag/infra",
+			wantCode: http.StatusOK,
+			wantBody: []string{"An old silent pond", "Over the wintry forest"},
+		},
+		{
+			name:        "Single snippet",
+			urlPath:     "/tag/billing",
+			wantCode:    http.StatusOK,
+			wantBody:    []string{"An old silent pond"},
+			notWantBody: []string{"Over the wintry forest"},
+		},
+		{
+			name:     "Unused tag",
+			urlPath:  "/tag/payroll",
+			wantCode: http.StatusOK,
+			wantBody: []string{"There are no snippets with this tag."},
+		},
+		{
+			name:     "Invalid tag",
+			urlPath:  "/tag/Bad%21",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid page size",
+			urlPath:  "/tag/infra?page_size=0",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+			for _, notWant := range tt.notWantBody {
+				assert.Equal(t, strings.Contains(body, notWant), false)
+			}
+		})
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    []string
+		notWantBody []string
+	}{
+		{
+			name:        "Alice",
+			urlPath:     "/user/profile/1",
+			wantCode:    http.StatusOK,
+			wantBody:    []string{"<h2>Alice Jones</h2>", "Joined 01 Jan 2022 at 10:00", "An old silent pond"},
+			notWantBody: []string{"First autumn morning", "Shopping list", "Over the wintry forest"},
+		},
+		{
+			name:        "Bob",
+			urlPath:     "/user/profile/2",
+			wantCode:    http.StatusOK,
+			wantBody:    []string{"<h2>Bob Smith</h2>", "Over the wintry forest", "/user/profile/2/feed.atom"},
+			notWantBody: []string{"Wi-Fi details", "An old silent pond"},
+		},
+		{
+			name:     "Out of range page",
+			urlPath:  "/user/profile/1?page=5",
+			wantCode: http.StatusOK,
+			wantBody: []string{"There are no snippets on this page."},
+		},
+		{
+			name:     "Non-existent user",
+			urlPath:  "/user/profile/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/user/profile/foo",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid sort",
+			urlPath:  "/user/profile/1?sort=password",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			for _, want := range tt.wantBody {
+				assert.StringContains(t, body, want)
+			}
+			for _, notWant := range tt.notWantBody {
+				assert.Equal(t, strings.Contains(body, notWant), false)
+			}
+		})
+	}
+}
+
+func TestSnippetCreatePostTags(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name     string
+		tags     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Valid tags",
+			tags:     "Billing, auth, billing",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Too many tags",
+			tags:     "a, b, c, d, e, f",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "A snippet cannot have more than 5 tags",
+		},
+		{
+			name:     "Invalid characters",
+			tags:     "billing, c++",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Tags can only contain letters, digits and dashes",
+		},
+		{
+			name:     "Too long",
+			tags:     strings.Repeat("a", 31),
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "Each tag cannot be more than 30 characters long",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "A frog")
+			form.Add("content", "package main")
+			form.Add("language", "go")
+			form.Add("visibility", "public")
+			form.Add("expires", "7d")
+			form.Add("tags", tt.tags)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// The tags from the valid submission are normalized.
+	_, _, body := ts.get(t, "/snippet/view/2")
+	assert.StringContains(t, body, "<a class='tag' href='/tag/billing'>billing</a> <a class='tag' href='/tag/auth'>auth</a>")
+}
+
+func TestAccountView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anonymous users are sent to the login page.
+	code, headers, _ := ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<td>Alice Jones</td>")
+	assert.StringContains(t, body, "<td>alice@example.com</td>")
+	assert.StringContains(t, body, "<td>01 Jan 2022 at 10:00</td>")
+	assert.StringContains(t, body, "<a href='/account/password/update'>Change password</a>")
+}
+
+func TestAccountPasswordUpdatePost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	const formTag = "<form action='/account/password/update' method='POST' novalidate>"
+
+	tests := []struct {
+		name            string
+		currentPassword string
+		newPassword     string
+		confirmation    string
+		wantCode        int
+		wantBody        string
+	}{
+		{
+			name:            "Valid submission",
+			currentPassword: "pa$$word",
+			newPassword:     "newPa$$word",
+			confirmation:    "newPa$$word",
+			wantCode:        http.StatusSeeOther,
+		},
+		{
+			name:            "Wrong current password",
+			currentPassword: "wrongPa$$word",
+			newPassword:     "newPa$$word",
+			confirmation:    "newPa$$word",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "Current password is incorrect",
+		},
+		{
+			name:            "Empty current password",
+			currentPassword: "",
+			newPassword:     "newPa$$word",
+			confirmation:    "newPa$$word",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        formTag,
+		},
+		{
+			name:            "Short new password",
+			currentPassword: "pa$$word",
+			newPassword:     "pa$$",
+			confirmation:    "pa$$",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "This field must be at least 8 characters long",
+		},
+		{
+			name:            "Mismatched confirmation",
+			currentPassword: "pa$$word",
+			newPassword:     "newPa$$word",
+			confirmation:    "newPa$$wrd",
+			wantCode:        http.StatusUnprocessableEntity,
+			wantBody:        "Passwords do not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("current_password", tt.currentPassword)
+			form.Add("new_password", tt.newPassword)
+			form.Add("new_password_confirmation", tt.confirmation)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/account/password/update", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusSeeOther {
+				assert.Equal(t, headers.Get("Location"), "/account/view")
+			}
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAccountPasswordUpdateOtherSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Log in from one browser and keep its cookies...
+	ts.login(t)
+	otherCookies := ts.Client().Jar.Cookies(u)
+
+	// ...then log in from another browser and change the password there.
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+
+	csrfToken := ts.login(t)
+
+	form := url.Values{}
+	form.Add("current_password", "pa$$word")
+	form.Add("new_password", "newPa$$word")
+	form.Add("new_password_confirmation", "newPa$$word")
+	form.Add("revoke_tokens", "true")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/account/password/update", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The browser which changed the password is still logged in.
+	code, _, _ = ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusOK)
+
+	// But the other one has been logged out.
+	jar, err = cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar.SetCookies(u, otherCookies)
+	ts.Client().Jar = jar
+
+	code, headers, _ := ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+}
@@ -2,40 +2,77 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+	"snippetbox.example.org/internal/highlight"
+	"snippetbox.example.org/internal/mailer"
+	"snippetbox.example.org/internal/models"
+	"snippetbox.example.org/internal/validator"
 )
 
 // The serverError helper writes an error message and stack trace to the errorLog,
 // then sends a generic 500 Internal Server Error response to the user.
-func (app *application) serverError(w http.ResponseWriter, err error) {
+func (app *application) serverError(w http.ResponseWriter, r *http.Request, err error) {
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace)
 
-	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	app.errorResponse(w, r, http.StatusInternalServerError)
 }
 
 // The clientError helper sends a specific status code and corresponding description
 // to the user. We'll this later in the book to send responses like 400 "Bad
 // Request" when there's a problem with the request that the user sent.
-func (app *application) clientError(w http.ResponseWriter, status int) {
-	http.Error(w, http.StatusText(status), status)
+func (app *application) clientError(w http.ResponseWriter, r *http.Request, status int) {
+	app.errorResponse(w, r, status)
 }
 
 // For consistency, we'll also implement a notFound helper. This is simply a
 // convenience wrapper around clientError which sends a 404 Not Found response to
 // the user.
-func (app *application) notFound(w http.ResponseWriter) {
-	app.clientError(w, http.StatusNotFound)
+func (app *application) notFound(w http.ResponseWriter, r *http.Request) {
+	app.clientError(w, r, http.StatusNotFound)
+}
+
+// The invalidToken() helper sends a 401 Unauthorized response when the API
+// token in the Authorization header is missing, malformed, expired or
+// revoked. The WWW-Authenticate header tells the client which scheme to use.
+func (app *application) invalidToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.clientError(w, r, http.StatusUnauthorized)
 }
 
-func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+// The errorResponse() helper sends the status code and its description in
+// the right format for the request. Requests to the JSON API get a JSON
+// object like {"error": "Not Found"}, and everything else gets plain text.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int) {
+	if isAPIRequest(r) {
+		err := app.writeJSON(w, status, envelope{"error": http.StatusText(status)}, nil)
+		if err != nil {
+			app.errorLog.Print(err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.Error(w, http.StatusText(status), status)
+}
+
+func (app *application) render(w http.ResponseWriter, r *http.Request, status int, page string, data *templateData) {
 	// Retrieve the appropiate template set from the cache based on the page
 	// name (like 'home.tmpl'). If no entry exists in the cache with the
 	// provided name, then create a new error and call the serverError() helper
@@ -43,7 +80,7 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	ts, ok := app.templateCache[page]
 	if !ok {
 		err := fmt.Errorf("the template %s does not exit", page)
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 		return
 	}
 
@@ -57,7 +94,7 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	// and the return.
 	err := ts.ExecuteTemplate(buf, "base", data)
 	if err != nil {
-		app.serverError(w, err)
+		app.serverError(w, r, err)
 	}
 
 	// Write out the provided HTTP stataus code ('200 OK', '400 Bad Request'
@@ -112,9 +149,10 @@ func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
 		CurrentYear: time.Now().Year(),
 		// Add the flash message to the template data, if one exits.
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
 	}
 }
 
@@ -127,3 +165,356 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// Return the ID of the current authenticated user, or 0 if the request is
+// from an anonymous user.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, ok := r.Context().Value(authenticatedUserIDContextKey).(int)
+	if !ok {
+		return 0
+	}
+	return id
+}
+
+// Return the snippet which was stored in the request context by the
+// requireSnippetOwner middleware. It's a programming error to call this from
+// a handler which isn't wrapped by that middleware, so we panic if it's
+// missing.
+func (app *application) contextSnippet(r *http.Request) *models.Snippet {
+	snippet, ok := r.Context().Value(snippetContextKey).(*models.Snippet)
+	if !ok {
+		panic("missing snippet value in request context")
+	}
+	return snippet
+}
+
+// The destroyOtherSessions() helper logs the user out of every session apart
+// from the one for the current request, so that after a password change
+// nobody who knew the old password is still logged in. Sessions are stored
+// as opaque blobs, so we have to look through all of them, but passwords
+// don't change often enough for that to matter.
+func (app *application) destroyOtherSessions(r *http.Request, userID int) error {
+	current := app.sessionManager.Token(r.Context())
+
+	return app.sessionManager.Iterate(r.Context(), func(ctx context.Context) error {
+		if app.sessionManager.Token(ctx) == current || app.sessionManager.GetInt(ctx, "authenticatedUserID") != userID {
+			return nil
+		}
+		return app.sessionManager.Destroy(ctx)
+	})
+}
+
+// The IDs of the password-protected snippets which the user has unlocked are
+// kept in their session under the "unlockedSnippets" key, so that they only
+// need to enter the password once per session.
+const unlockedSnippetsKey = "unlockedSnippets"
+
+// Return true if the snippet is password-protected and the current user
+// hasn't unlocked it yet. The owner of a snippet never needs its password.
+func (app *application) isLocked(r *http.Request, snippet *models.Snippet) bool {
+	if !snippet.Protected || snippet.UserID == app.authenticatedUserID(r) {
+		return false
+	}
+
+	unlocked, _ := app.sessionManager.Get(r.Context(), unlockedSnippetsKey).([]int)
+	return !slices.Contains(unlocked, snippet.ID)
+}
+
+// Record in the session that the current user has unlocked a snippet.
+func (app *application) rememberUnlock(r *http.Request, id int) {
+	unlocked, _ := app.sessionManager.Get(r.Context(), unlockedSnippetsKey).([]int)
+	if !slices.Contains(unlocked, id) {
+		app.sessionManager.Put(r.Context(), unlockedSnippetsKey, append(unlocked, id))
+	}
+}
+
+// The viewSnippet() helper fetches a snippet so that its content can be shown
+// to the current user. If the snippet has a password which they haven't
+// entered yet, it's returned with locked set to true and its content must not
+// be shown. We check this before calling View() so that a protected
+// burn-after-reading snippet isn't burnt by someone who doesn't know the
+// password. Otherwise burn-after-reading snippets are read again with View(),
+// which deletes them in the same transaction, so that only one request can
+// ever see the content.
+func (app *application) viewSnippet(r *http.Request, id int) (snippet *models.Snippet, locked bool, err error) {
+	snippet, err = app.snippets.Get(id, app.authenticatedUserID(r))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if app.isLocked(r, snippet) {
+		return snippet, true, nil
+	}
+
+	if snippet.BurnAfterReading {
+		snippet, err = app.snippets.View(id, app.authenticatedUserID(r))
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return snippet, false, nil
+}
+
+// The filenameRX regular expression matches runs of characters which we
+// don't want in a download filename.
+var filenameRX = regexp.MustCompile(`[^a-z0-9]+`)
+
+// The snippetFilename() function returns the filename to use when a snippet
+// is downloaded, made from its title and the file extension for its
+// language. For example, a Go snippet called "Hello, World!" is downloaded as
+// "hello-world.go". If nothing is left of the title we use the snippet ID.
+func snippetFilename(snippet *models.Snippet) string {
+	name := filenameRX.ReplaceAllString(strings.ToLower(snippet.Title), "-")
+	name = strings.Trim(name, "-")
+
+	if len(name) > 50 {
+		name = strings.TrimRight(name[:50], "-")
+	}
+
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", snippet.ID)
+	}
+
+	return name + highlight.Extension(snippet.Language)
+}
+
+// The findFile() function returns the snippet's extra file with the given
+// name, or nil if there isn't one.
+func findFile(snippet *models.Snippet, name string) *models.File {
+	for _, f := range snippet.Files {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// The readIDParam() helper reads the "id" named parameter from the request
+// context and converts it to a positive integer. If it's missing or invalid
+// we return an error, which callers will normally turn into a 404 response.
+func (app *application) readIDParam(r *http.Request) (int, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+
+	return id, nil
+}
+
+// The readString() helper returns a string value from the query string, or the
+// provided default value if no matching key could be found.
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+// The readInt() helper reads a string value from the query string and converts
+// it to an integer before returning. If no matching key could be found it
+// returns the provided default value. If the value couldn't be converted to an
+// integer, then we record an error message in the provided Validator instance.
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddFieldError(key, "must be an integer value")
+		return defaultValue
+	}
+
+	return i
+}
+
+// The expiryLayouts are the date-time formats accepted by parseExpiry(). The
+// first is the format used by <input type='datetime-local'> elements.
+var expiryLayouts = []string{"2006-01-02T15:04", "2006-01-02 15:04", time.RFC3339}
+
+// The parseExpiry() helper converts an expiry option into the time at which a
+// snippet should expire. The value can be "never" (which returns the zero
+// time), a duration such as "10m", "3h" or "30d" counted from now, or an exact
+// date and time. Date-times without a time zone are treated as UTC, which is
+// the zone we display all times in.
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	if value == "never" {
+		return time.Time{}, nil
+	}
+
+	if d, err := validator.ParseDuration(value); err == nil {
+		return now.Add(d), nil
+	}
+
+	for _, layout := range expiryLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid expiry value %q", value)
+}
+
+// The background() helper runs fn in a new goroutine, recovering and logging
+// any panic so that it can't bring down the whole server. The application's
+// WaitGroup tracks the goroutine, so that we can wait for it to finish before
+// shutting down.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Output(2, fmt.Sprintf("%s\n%s", err, debug.Stack()))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+// The sendEmail() helper renders an email template and sends it in the
+// background, so that the response doesn't wait for the mail server. Any
+// error is logged, because by then there's nobody left to tell.
+func (app *application) sendEmail(recipient, templateFile string, data any) {
+	app.background(func() {
+		msg, err := mailer.NewMessage(recipient, templateFile, data)
+		if err != nil {
+			app.errorLog.Print(err)
+			return
+		}
+
+		err = app.mailer.Send(msg)
+		if err != nil {
+			app.errorLog.Print(err)
+		}
+	})
+}
+
+// The envelope type is used to wrap the data in our JSON responses, so that
+// every response is a JSON object with a descriptive top-level key, like
+// {"snippet": {...}}.
+type envelope map[string]any
+
+// Return true if the request is for one of the JSON API endpoints.
+func isAPIRequest(r *http.Request) bool {
+	return r != nil && strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// The writeJSON() helper encodes the data as JSON and sends it with the given
+// status code and any additional headers. We encode everything before writing
+// anything, so that if encoding fails we haven't already sent a partial
+// response.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// The maxJSONBytes constant limits the size of JSON request bodies to 1MB.
+const maxJSONBytes = 1_048_576
+
+// The readJSON() helper decodes a JSON request body into dst. It's strict
+// about what it accepts: the body must be a single JSON object no larger
+// than maxJSONBytes, with no fields that dst doesn't know about. Any problem
+// is returned as an error with a message that's safe to send to the client.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxError *json.SyntaxError
+		var unmarshalTypeError *json.UnmarshalTypeError
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return fmt.Errorf("body contains unknown key %s", fieldName)
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	// Call Decode() again to make sure that there's nothing after the first
+	// JSON value in the body.
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// The badRequestJSON() helper sends a 400 Bad Request response with a
+// description of what was wrong with the request body.
+func (app *application) badRequestJSON(w http.ResponseWriter, r *http.Request, err error) {
+	data := envelope{"error": http.StatusText(http.StatusBadRequest), "detail": err.Error()}
+
+	err = app.writeJSON(w, http.StatusBadRequest, data, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// The failedValidation() helper sends a 422 Unprocessable Entity response
+// containing the errors collected by a validator, so that API clients get the
+// same messages as the HTML forms show.
+func (app *application) failedValidation(w http.ResponseWriter, r *http.Request, v validator.Validator) {
+	fieldErrors := v.FieldErrors
+	if fieldErrors == nil {
+		fieldErrors = map[string]string{}
+	}
+
+	nonFieldErrors := v.NonFieldErrors
+	if nonFieldErrors == nil {
+		nonFieldErrors = []string{}
+	}
+
+	data := envelope{
+		"error":            http.StatusText(http.StatusUnprocessableEntity),
+		"field_errors":     fieldErrors,
+		"non_field_errors": nonFieldErrors,
+	}
+
+	err := app.writeJSON(w, http.StatusUnprocessableEntity, data, nil)
+	if err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.example.org/internal/assert"
+	"snippetbox.example.org/internal/models"
+)
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:  "Never",
+			value: "never",
+			want:  time.Time{},
+		},
+		{
+			name:  "Minutes",
+			value: "10m",
+			want:  now.Add(10 * time.Minute),
+		},
+		{
+			name:  "Hours and minutes",
+			value: "1h30m",
+			want:  now.Add(90 * time.Minute),
+		},
+		{
+			name:  "Days",
+			value: "30d",
+			want:  now.AddDate(0, 0, 30),
+		},
+		{
+			name:  "Weeks",
+			value: "2w",
+			want:  now.AddDate(0, 0, 14),
+		},
+		{
+			name:  "Datetime-local",
+			value: "2024-12-31T18:00",
+			want:  time.Date(2024, 12, 31, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "RFC 3339",
+			value: "2024-12-31T18:00:00+01:00",
+			want:  time.Date(2024, 12, 31, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "Negative duration",
+			value:   "-3h",
+			wantErr: true,
+		},
+		{
+			name:    "Too many days",
+			value:   "99999999d",
+			wantErr: true,
+		},
+		{
+			name:    "Too many weeks",
+			value:   "36500w",
+			wantErr: true,
+		},
+		{
+			name:    "Just over 100 years of weeks",
+			value:   "5215w",
+			wantErr: true,
+		},
+		{
+			name:  "Just under 100 years of weeks",
+			value: "5214w",
+			want:  now.AddDate(0, 0, 5214*7),
+		},
+		{
+			name:    "Garbage",
+			value:   "tomorrow",
+			wantErr: true,
+		},
+		{
+			name:    "Empty",
+			value:   "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExpiry(tt.value, now)
+
+			assert.Equal(t, err != nil, tt.wantErr)
+			assert.Equal(t, got.Equal(tt.want), true)
+		})
+	}
+}
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{
+			name:    "Punctuation",
+			snippet: &models.Snippet{ID: 1, Title: "Hello, World!", Language: "go"},
+			want:    "hello-world.go",
+		},
+		{
+			name:    "Path separators",
+			snippet: &models.Snippet{ID: 1, Title: "../../etc/passwd", Language: "plaintext"},
+			want:    "etc-passwd.txt",
+		},
+		{
+			name:    "Non-ASCII title",
+			snippet: &models.Snippet{ID: 7, Title: "古池や", Language: "yaml"},
+			want:    "snippet-7.yaml",
+		},
+		{
+			name:    "Long title",
+			snippet: &models.Snippet{ID: 1, Title: strings.Repeat("ab ", 30), Language: "sql"},
+			want:    "ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab-ab.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, snippetFilename(tt.snippet), tt.want)
+		})
+	}
+}
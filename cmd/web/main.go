@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	// Import the models package that we just created. You need to prefix this with
 	// wharever module path you set up back, so that import statement looks like this:
 	// "{your-module-path}/internal/models"
+	"snippetbox.example.org/internal/mailer"
 	"snippetbox.example.org/internal/models"
 
 	"github.com/alexedwards/scs/mysqlstore"
@@ -29,14 +36,32 @@ import (
 // Add a formDecoder field to hold a pointer to a form.Decoder instance.
 // Add a new sessionManager field to the application struct.
 // Initialize a models.UserModel instance and add it to the application
+// The sessions field is only used by the purge worker to clear out expired
+// sessions; the session manager reads and writes them itself.
+// The publicURL is used to build absolute links, like the ones in emails and
+// the paste response, and the WaitGroup tracks emails which are still being
+// sent in the background. The password reset limiters are keyed by email
+// address and by IP address, and the verification resend limiter by user ID.
 type application struct {
-	errorLog       *log.Logger
-	infoLog        *log.Logger
-	snippets       models.SnippetModelInterface
-	users          models.UserModelInterface
-	templateCache  map[string]*template.Template
-	formDecoder    *form.Decoder
-	sessionManager *scs.SessionManager
+	errorLog            *log.Logger
+	infoLog             *log.Logger
+	snippets            models.SnippetModelInterface
+	users               models.UserModelInterface
+	sessions            models.SessionModelInterface
+	tokens              models.TokenModelInterface
+	comments            models.CommentModelInterface
+	stars               models.StarModelInterface
+	oneTimeTokens       models.OneTimeTokenModelInterface
+	mailer              mailer.Mailer
+	publicURL           string
+	wg                  sync.WaitGroup
+	templateCache       map[string]*template.Template
+	formDecoder         *form.Decoder
+	sessionManager      *scs.SessionManager
+	unlockLimiter       *attemptLimiter[int]
+	resetEmailLimiter   *attemptLimiter[string]
+	resetIPLimiter      *attemptLimiter[string]
+	verifyResendLimiter *attemptLimiter[int]
 }
 
 func main() {
@@ -48,6 +73,23 @@ func main() {
 	// Define a new command-line flag for the MySQL DNS string.
 	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
 
+	// Define flags to control how often expired snippets and sessions are
+	// deleted, and how many rows are deleted by each statement.
+	purgeInterval := flag.Duration("purge-interval", 10*time.Minute, "Interval between purges of expired snippets and sessions")
+	purgeBatchSize := flag.Int("purge-batch-size", 1000, "Maximum number of rows deleted by each purge statement")
+
+	// Define flags for the public URL of the site, which is used in absolute
+	// links such as the ones we send by email, and for the SMTP server which
+	// sends them. Emails hold password reset links, so they're only written
+	// to the info log instead if that's asked for with -mailer=log.
+	publicURL := flag.String("public-url", "https://localhost:4000", "Public base URL of the site, used in absolute links")
+	mailerKind := flag.String("mailer", "smtp", "How to send emails: smtp, or log to write them to the info log (development only)")
+	smtpHost := flag.String("smtp-host", "", "SMTP host (required with -mailer=smtp)")
+	smtpPort := flag.Int("smtp-port", 25, "SMTP port")
+	smtpUsername := flag.String("smtp-username", "", "SMTP username")
+	smtpPassword := flag.String("smtp-password", "", "SMTP password")
+	smtpSender := flag.String("smtp-sender", "Snippetbox <no-reply@snippetbox.example.org>", "SMTP sender")
+
 	// Importantly, we use the flag.Parse() function to parse the command-line flag.
 	// This reads in the command-line flag value and assigns it to the addr
 	// variable. You need to call this *before* you use teh addr variable
@@ -67,6 +109,15 @@ func main() {
 	// file name and line number.
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	// A purge interval or batch size of zero or less would make the purge
+	// worker hammer the database in a tight loop, so refuse to start.
+	if *purgeInterval <= 0 {
+		errorLog.Fatal("-purge-interval must be greater than zero")
+	}
+	if *purgeBatchSize <= 0 {
+		errorLog.Fatal("-purge-batch-size must be greater than zero")
+	}
+
 	// To keep the main() function tidy I've put the code for creating a connection
 	// pool into the separate openDB() function below. We pass openDB() the DSN
 	// from the command-line flag.
@@ -91,9 +142,11 @@ func main() {
 	// Use the scs.New() function to initialize a new session manager. Then we
 	// configure it to use our MySQL databse as the session store, and set a
 	// lifetime of 12 hours (so that sessions automatically expire 12 hours
-	// after first being created).
+	// after first being created). A cleanup interval of 0 turns off the
+	// store's own cleanup goroutine, because our purge worker deletes expired
+	// sessions in batches instead.
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
+	sessionManager.Store = mysqlstore.NewWithCleanupInterval(db, 0)
 	sessionManager.Lifetime = 12 * time.Hour
 
 	// Make sure that the Secure attribute is set on our session cookies.
@@ -102,6 +155,30 @@ func main() {
 	// unsecure HTTP connection).
 	sessionManager.Cookie.Secure = true
 
+	// Use the SMTP mailer unless we've been told to just log the emails, so
+	// that the links can be followed in development. A production server
+	// which was started without an SMTP host would otherwise write live
+	// password reset links to its logs, so we refuse to start instead.
+	var m mailer.Mailer
+
+	switch *mailerKind {
+	case "smtp":
+		if *smtpHost == "" {
+			errorLog.Fatal("-smtp-host is required (use -mailer=log to log emails in development)")
+		}
+		m = &mailer.SMTPMailer{
+			Host:     *smtpHost,
+			Port:     *smtpPort,
+			Username: *smtpUsername,
+			Password: *smtpPassword,
+			Sender:   *smtpSender,
+		}
+	case "log":
+		m = &mailer.LogMailer{Logger: infoLog}
+	default:
+		errorLog.Fatalf("-mailer must be smtp or log, not %q", *mailerKind)
+	}
+
 	// Initialize a new instance of our application struct, containing the
 	// dependencies.
 	// Initialize a models.SnippetModel instance and add it to the application
@@ -114,9 +191,26 @@ func main() {
 		infoLog:        infoLog,
 		snippets:       &models.SnippetModel{DB: db},
 		users:          &models.UserModel{DB: db},
+		sessions:       &models.SessionModel{DB: db},
+		tokens:         &models.TokenModel{DB: db},
+		comments:       &models.CommentModel{DB: db},
+		stars:          &models.StarModel{DB: db},
+		oneTimeTokens:  &models.OneTimeTokenModel{DB: db},
+		mailer:         m,
+		publicURL:      strings.TrimSuffix(*publicURL, "/"),
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
+		// Allow five wrong passwords for each protected snippet in any 15
+		// minute period.
+		unlockLimiter: newAttemptLimiter[int](5, 15*time.Minute, realClock{}),
+		// Allow three password reset emails to each address, and ten
+		// requests from each IP address, in any hour.
+		resetEmailLimiter: newAttemptLimiter[string](3, time.Hour, realClock{}),
+		resetIPLimiter:    newAttemptLimiter[string](10, time.Hour, realClock{}),
+		// Allow three confirmation emails to be resent to each user in any
+		// hour.
+		verifyResendLimiter: newAttemptLimiter[int](3, time.Hour, realClock{}),
 	}
 
 	// Initialize a tls.config struct to hold the non-default TLS setting we
@@ -142,6 +236,35 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// Create a context which is cancelled when the process receives a SIGINT
+	// (Ctrl+C) or SIGTERM signal. Everything running in the background watches
+	// this context so that it knows when to stop.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start the purge worker in a background goroutine. We use a WaitGroup so
+	// that we can wait for it to finish before main() returns and the
+	// database connection pool is closed.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		app.runPurger(ctx, realClock{}, *purgeInterval, *purgeBatchSize)
+	}()
+
+	// When the context is cancelled, give any in-flight requests up to 10
+	// seconds to complete before shutting the server down.
+	shutdownErr := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		infoLog.Print("Shutting down server")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		shutdownErr <- srv.Shutdown(shutdownCtx)
+	}()
+
 	// The value returned from the flag.Parse() function is a pointer to the flag
 	// value, not the value ifselt. So we need to dereference the pointer (i.e.
 	// prefix it with the * symbol) before using it. Note that we're using the
@@ -156,7 +279,23 @@ func main() {
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
 	// Call the ListenAndServe() method on our new http.Server struct.
 	// err = srv.ListenAndServe()
-	errorLog.Fatal(err)
+
+	// Calling Shutdown() makes ListenAndServeTLS() return straight away with
+	// http.ErrServerClosed, so any other error means the server failed to
+	// start or stopped unexpectedly.
+	if !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Fatal(err)
+	}
+
+	if err := <-shutdownErr; err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Wait for the purge worker to stop, and for any emails which are still
+	// being sent.
+	wg.Wait()
+	app.wg.Wait()
+	infoLog.Print("Server stopped")
 }
 
 // The openDB() function wraps sql.Open() and returns a sql.DB connection pool
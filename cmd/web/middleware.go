@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/justinas/nosurf"
+	"snippetbox.example.org/internal/models"
 )
 
 func secureHeaders(next http.Handler) http.Handler {
@@ -41,7 +44,7 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 				w.Header().Set("Connection", "close")
 				// Call the app.serverError helper method to return a 500
 				// Internal Server response.
-				app.serverError(w, fmt.Errorf("%s", err))
+				app.serverError(w, r, fmt.Errorf("%s", err))
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -52,8 +55,13 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// If the user is not authenticated, redirect them to the login page and
 		// return from the middleware chain so that no subsequent handlers in
-		// the chain are executed.
+		// the chain are executed. API clients can't follow a redirect to a
+		// login form, so they get a 401 Unauthorized response instead.
 		if !app.isAuthenticated(r) {
+			if isAPIRequest(r) {
+				app.clientError(w, r, http.StatusUnauthorized)
+				return
+			}
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 			return
 		}
@@ -80,6 +88,64 @@ func noSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
+// The noSurfForSession middleware is used on the API and paste routes, and
+// must come after authenticate in the chain. Requests which are logged in by
+// the session cookie get the usual CSRF check, so they must send the CSRF
+// token in an X-CSRF-Token header. Requests with an Authorization header
+// skip it, because other sites can't make a browser send that header and
+// authenticateToken ignores the session when it's there. So do anonymous
+// requests, which can't do anything worth forging, so that command-line
+// clients which forget their token still get a 401 Unauthorized response.
+func (app *application) noSurfForSession(next http.Handler) http.Handler {
+	csrfHandler := noSurf(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || !app.isAuthenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		csrfHandler.ServeHTTP(w, r)
+	})
+}
+
+// The authenticateToken middleware lets non-browser clients authenticate with
+// a personal API token in an "Authorization: Bearer <token>" header instead
+// of a session cookie. It's only used on the API and paste routes, so a
+// token can't be used to reach the account pages, where it could create new
+// tokens or change the password. It must come after authenticate in the
+// chain. If the header is present at all it must hold a valid token, and it
+// takes the place of any session.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(authorization, "Bearer ")
+		if !ok || token == "" {
+			app.invalidToken(w, r)
+			return
+		}
+
+		id, err := app.tokens.Authenticate(token)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.invalidToken(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+		ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Retrieve the authenticatedUserID value from the sessioon using the
@@ -96,7 +162,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// database.
 		exists, err := app.users.Exists(id)
 		if err != nil {
-			app.serverError(w, err)
+			app.serverError(w, r, err)
 			return
 		}
 
@@ -104,8 +170,11 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		// coming from an authenticated user who exists in our database. We
 		// create a new copy of the request (with an isAuthenticatedContextKey
 		// value of true in the request context) and assign it to r.
+		// We also add the user ID to the context, so that it's available to
+		// any handlers which need to know who the current user is.
 		if exists {
 			ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+			ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
 			r = r.WithContext(ctx)
 		}
 
@@ -113,3 +182,40 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// The requireSnippetOwner middleware is used on routes which act on an
+// existing snippet. It must come after requireAuthentication in the chain. If
+// the snippet doesn't exist we send a 404 Not Found response, and if it
+// belongs to someone other than the current user we send a 403 Forbidden.
+func (app *application) requireSnippetOwner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// httprouter has already stored the named parameters in the request
+		// context by the time our middleware chain is called, so we can read
+		// the id in exactly the same way as the handlers do.
+		id, err := app.readIDParam(r)
+		if err != nil {
+			app.notFound(w, r)
+			return
+		}
+
+		snippet, err := app.snippets.Get(id, app.authenticatedUserID(r))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.notFound(w, r)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+
+		if snippet.UserID != app.authenticatedUserID(r) {
+			app.clientError(w, r, http.StatusForbidden)
+			return
+		}
+
+		// Store the snippet in the request context so that the handler
+		// doesn't need to fetch it from the database a second time.
+		ctx := context.WithValue(r.Context(), snippetContextKey, snippet)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The maxPasteBytes constant limits the size of a paste. The content column
+// is a MySQL TEXT column, which can't hold more than 65,535 bytes.
+const maxPasteBytes = 65_535
+
+// The snippetPaste handler creates a snippet from a raw request body, for
+// use from the command line:
+//
+//	cat build.log | curl --data-binary @- -H "Authorization: Bearer $TOKEN" https://host/paste?title=Build+log
+//
+// The title, language, expires, visibility and burn_after_reading options can
+// be given as query string parameters or as Snippet-Title, Snippet-Language,
+// Snippet-Expires, Snippet-Visibility, Snippet-Burn-After-Reading and
+// Snippet-Tags headers.
+// The password can only be given in the Snippet-Password header, so that it
+// doesn't end up in anyone's access logs. Everything is checked with the
+// same rules as the create form, and the response is the URL of the new
+// snippet as plain text.
+func (app *application) snippetPaste(w http.ResponseWriter, r *http.Request) {
+	if !app.isAuthenticated(r) {
+		app.invalidToken(w, r)
+		return
+	}
+
+	// Read the whole body. If it's over the limit, MaxBytesReader stops
+	// reading and returns a *http.MaxBytesError.
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasteBytes)
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.clientError(w, r, http.StatusRequestEntityTooLarge)
+		} else {
+			app.clientError(w, r, http.StatusBadRequest)
+		}
+		return
+	}
+
+	visibility, err := app.defaultVisibility(r)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	qs := r.URL.Query()
+
+	// The option() function returns the value of a query string parameter,
+	// falling back to the matching header.
+	option := func(key, header, defaultValue string) string {
+		if value := qs.Get(key); value != "" {
+			return value
+		}
+		if value := r.Header.Get(header); value != "" {
+			return value
+		}
+		return defaultValue
+	}
+
+	form := snippetCreateForm{
+		Title:            option("title", "Snippet-Title", "Untitled"),
+		Content:          string(content),
+		Language:         option("language", "Snippet-Language", "plaintext"),
+		Visibility:       option("visibility", "Snippet-Visibility", visibility),
+		Expires:          option("expires", "Snippet-Expires", "365d"),
+		BurnAfterReading: option("burn_after_reading", "Snippet-Burn-After-Reading", "false") == "true",
+		Password:         r.Header.Get("Snippet-Password"),
+		Tags:             option("tags", "Snippet-Tags", ""),
+	}
+
+	expires := form.validate(time.Now())
+
+	err = app.checkVisibility(r, &form.Validator, form.Visibility)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		writeValidationErrors(w, form.FieldErrors)
+		return
+	}
+
+	id, err := app.snippets.Insert(form.snippet(expires, app.authenticatedUserID(r)))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Reply with the full URL of the new snippet, so that it can be piped
+	// straight into something else. It's built from the configured public
+	// URL rather than the request, because the Host header can't be trusted
+	// and a proxy in front of us may have terminated TLS.
+	url := fmt.Sprintf("%s/snippet/view/%d", app.publicURL, id)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Location", url)
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, url)
+}
+
+// The writeValidationErrors() function sends a 422 Unprocessable Entity
+// response listing the validation errors as plain text, one "field: message"
+// pair per line, in alphabetical order of field name.
+func writeValidationErrors(w http.ResponseWriter, fieldErrors map[string]string) {
+	fields := make([]string, 0, len(fieldErrors))
+	for field := range fieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var b strings.Builder
+	for _, field := range fields {
+		fmt.Fprintf(&b, "%s: %s\n", field, fieldErrors[field])
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	io.WriteString(w, b.String())
+}
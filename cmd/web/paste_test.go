@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"snippetbox.example.org/internal/assert"
+	"snippetbox.example.org/internal/models"
+)
+
+func TestSnippetPaste(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name          string
+		query         string
+		headers       map[string]string
+		body          string
+		authorization string
+		wantCode      int
+		wantBody      string
+	}{
+		{
+			name:          "Defaults",
+			body:          "ok  snippetbox.example.org/cmd/web",
+			authorization: "Bearer valid-token",
+			wantCode:      http.StatusCreated,
+			wantBody:      "https://snippetbox.example.org/snippet/view/2\n",
+		},
+		{
+			name:          "Query parameters",
+			query:         "?title=Build+log&language=go&expires=1h&visibility=unlisted",
+			body:          "package main",
+			authorization: "Bearer valid-token",
+			wantCode:      http.StatusCreated,
+			wantBody:      "https://snippetbox.example.org/snippet/view/2\n",
+		},
+		{
+			name:          "Headers",
+			headers:       map[string]string{"Snippet-Title": "Build log", "Snippet-Expires": "never", "Snippet-Password": "letmein"},
+			body:          "package main",
+			authorization: "Bearer valid-token",
+			wantCode:      http.StatusCreated,
+			wantBody:      "https://snippetbox.example.org/snippet/view/2\n",
+		},
+		{
+			name:     "No token",
+			body:     "package main",
+			wantCode: http.StatusUnauthorized,
+			wantBody: "Unauthorized",
+		},
+		{
+			name:          "Empty body",
+			body:          "",
+			authorization: "Bearer valid-token",
+			wantCode:      http.StatusUnprocessableEntity,
+			wantBody:      "content: This field must cannot be blank\n",
+		},
+		{
+			name:          "Invalid options",
+			query:         "?language=cobol&expires=soon",
+			body:          "package main",
+			authorization: "Bearer valid-token",
+			wantCode:      http.StatusUnprocessableEntity,
+			wantBody:      "expires: This field must be a duration like 10m, 3h or 30d, a date and time, or never\nlanguage: This field must be one of the listed languages\n",
+		},
+		{
+			name:          "Binary content",
+			body:          "\xff\xfe\x00",
+			authorization: "Bearer valid-token",
+			wantCode:      http.StatusUnprocessableEntity,
+			wantBody:      "content: This field must be valid UTF-8 text\n",
+		},
+		{
+			name:          "Too large",
+			body:          strings.Repeat("a", maxPasteBytes+1),
+			authorization: "Bearer valid-token",
+			wantCode:      http.StatusRequestEntityTooLarge,
+			wantBody:      "Request Entity Too Large",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/paste"+tt.query, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rs.Body.Close()
+
+			body, err := io.ReadAll(rs.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, rs.StatusCode, tt.wantCode)
+			assert.StringContains(t, string(body), tt.wantBody)
+		})
+	}
+}
+
+func TestSnippetPasteUnverified(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.loginAs(t, "carol@example.com")
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/paste", strings.NewReader("package main"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-CSRF-Token", csrfToken)
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	assert.Equal(t, rs.StatusCode, http.StatusCreated)
+
+	snippet, err := app.snippets.Get(2, 3)
+	assert.NilError(t, err)
+	assert.Equal(t, snippet.Visibility, models.VisibilityUnlisted)
+}
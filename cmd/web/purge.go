@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// The clock interface lets the purge worker find out the current time and
+// wait for the next run without calling the time package directly. In
+// production we use realClock, and in the tests we swap in a fake clock that
+// only moves forward when we tell it to.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// The runPurger() method deletes expired snippets, sessions and tokens every
+// interval, until the context is cancelled. It's meant to be run in its own
+// goroutine, and it returns once any purge which is in progress has stopped.
+func (app *application) runPurger(ctx context.Context, clk clock, interval time.Duration, batchSize int) {
+	app.infoLog.Printf("Purging expired snippets and sessions every %s", interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			app.infoLog.Print("Stopped purging expired snippets and sessions")
+			return
+		case <-clk.After(interval):
+			app.purgeExpired(ctx, clk.Now(), batchSize)
+		}
+	}
+}
+
+// The purgeExpired() method runs a single purge, deleting everything which
+// expired before now. A failure to purge snippets is logged but doesn't stop
+// us from purging sessions and tokens, and we'll try again on the next run
+// anyway. We also take the opportunity to sweep old keys out of the rate
+// limiters.
+func (app *application) purgeExpired(ctx context.Context, now time.Time, batchSize int) {
+	app.unlockLimiter.Sweep(now)
+	app.resetEmailLimiter.Sweep(now)
+	app.resetIPLimiter.Sweep(now)
+	app.verifyResendLimiter.Sweep(now)
+
+	snippets, err := purgeInBatches(ctx, app.snippets.DeleteExpired, now, batchSize)
+	if err != nil {
+		app.errorLog.Printf("purging expired snippets: %s", err)
+	}
+
+	sessions, err := purgeInBatches(ctx, app.sessions.DeleteExpired, now, batchSize)
+	if err != nil {
+		app.errorLog.Printf("purging expired sessions: %s", err)
+	}
+
+	tokens, err := purgeInBatches(ctx, app.tokens.DeleteExpired, now, batchSize)
+	if err != nil {
+		app.errorLog.Printf("purging expired API tokens: %s", err)
+	}
+
+	oneTimeTokens, err := purgeInBatches(ctx, app.oneTimeTokens.DeleteExpired, now, batchSize)
+	if err != nil {
+		app.errorLog.Printf("purging expired one-time tokens: %s", err)
+	}
+
+	app.infoLog.Printf("Purged %d expired snippets, %d expired sessions, %d expired API tokens and %d expired one-time tokens", snippets, sessions, tokens, oneTimeTokens)
+}
+
+// The purgeInBatches() function calls deleteExpired repeatedly until a batch
+// comes back smaller than batchSize, which means there's nothing left to
+// delete. It returns the total number of rows deleted. We check the context
+// between batches so that a large backlog doesn't hold up a shutdown.
+func purgeInBatches(ctx context.Context, deleteExpired func(time.Time, int) (int, error), before time.Time, batchSize int) (int, error) {
+	total := 0
+
+	for ctx.Err() == nil {
+		n, err := deleteExpired(before, batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+
+		if n < batchSize {
+			break
+		}
+	}
+
+	return total, nil
+}
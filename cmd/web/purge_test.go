@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"snippetbox.example.org/internal/assert"
+	"snippetbox.example.org/internal/models/mocks"
+)
+
+// The fakeClock type only moves forward when Advance() is called. Each call
+// to After() is announced on the sleeping channel, so that a test can wait
+// until the worker is blocked before advancing the clock.
+type fakeClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	timers   []fakeTimer
+	sleeping chan time.Duration
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, sleeping: make(chan time.Duration, 1)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	c.mu.Unlock()
+
+	c.sleeping <- d
+	return ch
+}
+
+// Advance moves the clock forward and fires any timers which are now due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		timer.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// The fakePurger type returns the given batch sizes in turn from
+// DeleteExpired(), followed by err (if any), and records the arguments of
+// every call.
+type fakePurger struct {
+	mu      sync.Mutex
+	batches []int
+	err     error
+	calls   []time.Time
+}
+
+func (p *fakePurger) DeleteExpired(before time.Time, limit int) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls = append(p.calls, before)
+
+	if len(p.batches) == 0 {
+		return 0, p.err
+	}
+
+	n := p.batches[0]
+	p.batches = p.batches[1:]
+	return n, nil
+}
+
+func (p *fakePurger) callCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.calls)
+}
+
+// The snippetPurger type lets us use a fakePurger in place of the mock
+// snippet model's DeleteExpired() method.
+type snippetPurger struct {
+	mocks.SnippetModel
+	*fakePurger
+}
+
+func (p *snippetPurger) DeleteExpired(before time.Time, limit int) (int, error) {
+	return p.fakePurger.DeleteExpired(before, limit)
+}
+
+// The tokenPurger type does the same for the mock token model.
+type tokenPurger struct {
+	mocks.TokenModel
+	*fakePurger
+}
+
+func (p *tokenPurger) DeleteExpired(before time.Time, limit int) (int, error) {
+	return p.fakePurger.DeleteExpired(before, limit)
+}
+
+// The oneTimeTokenPurger type does the same for the mock one-time token
+// model.
+type oneTimeTokenPurger struct {
+	mocks.OneTimeTokenModel
+	*fakePurger
+}
+
+func (p *oneTimeTokenPurger) DeleteExpired(before time.Time, limit int) (int, error) {
+	return p.fakePurger.DeleteExpired(before, limit)
+}
+
+func TestRunPurger(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	interval := 10 * time.Minute
+
+	snippets := &fakePurger{batches: []int{2, 2, 1}}
+	sessions := &fakePurger{batches: []int{1}}
+
+	var infoLog bytes.Buffer
+
+	app := newTestApplication(t)
+	app.infoLog = log.New(&infoLog, "", 0)
+	app.snippets = &snippetPurger{fakePurger: snippets}
+	app.sessions = sessions
+
+	clk := newFakeClock(start)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		app.runPurger(ctx, clk, interval, 2)
+		close(done)
+	}()
+
+	// Nothing should be purged until the full interval has passed.
+	assert.Equal(t, <-clk.sleeping, interval)
+	clk.Advance(interval - time.Second)
+	assert.Equal(t, snippets.callCount(), 0)
+
+	// Once it has, the worker should keep deleting snippets until it gets a
+	// short batch, then delete sessions, and then go back to sleep.
+	clk.Advance(time.Second)
+	assert.Equal(t, <-clk.sleeping, interval)
+
+	assert.Equal(t, snippets.callCount(), 3)
+	assert.Equal(t, sessions.callCount(), 1)
+	assert.Equal(t, snippets.calls[0].Equal(start.Add(interval)), true)
+	assert.StringContains(t, infoLog.String(), "Purged 5 expired snippets, 1 expired sessions, 0 expired API tokens and 0 expired one-time tokens")
+
+	// Cancelling the context should stop the worker.
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purge worker did not stop")
+	}
+
+	assert.StringContains(t, infoLog.String(), "Stopped purging expired snippets and sessions")
+}
+
+func TestPurgeExpired(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		snippets     *fakePurger
+		sessions     *fakePurger
+		tokens       *fakePurger
+		onetime      *fakePurger
+		wantInfo     string
+		wantError    string
+		wantSnippets int
+	}{
+		{
+			name:         "Nothing to purge",
+			snippets:     &fakePurger{},
+			sessions:     &fakePurger{},
+			tokens:       &fakePurger{},
+			onetime:      &fakePurger{},
+			wantInfo:     "Purged 0 expired snippets, 0 expired sessions, 0 expired API tokens and 0 expired one-time tokens",
+			wantSnippets: 1,
+		},
+		{
+			name:         "Exact multiple of batch size",
+			snippets:     &fakePurger{batches: []int{3, 3}},
+			sessions:     &fakePurger{batches: []int{2}},
+			tokens:       &fakePurger{batches: []int{1}},
+			onetime:      &fakePurger{batches: []int{3, 2}},
+			wantInfo:     "Purged 6 expired snippets, 2 expired sessions, 1 expired API tokens and 5 expired one-time tokens",
+			wantSnippets: 3,
+		},
+		{
+			name:         "Snippet error",
+			snippets:     &fakePurger{batches: []int{3}, err: errors.New("boom")},
+			sessions:     &fakePurger{batches: []int{1}},
+			tokens:       &fakePurger{},
+			onetime:      &fakePurger{},
+			wantInfo:     "Purged 3 expired snippets, 1 expired sessions, 0 expired API tokens and 0 expired one-time tokens",
+			wantError:    "purging expired snippets: boom",
+			wantSnippets: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var infoLog, errorLog bytes.Buffer
+
+			app := newTestApplication(t)
+			app.infoLog = log.New(&infoLog, "", 0)
+			app.errorLog = log.New(&errorLog, "", 0)
+			app.snippets = &snippetPurger{fakePurger: tt.snippets}
+			app.sessions = tt.sessions
+			app.tokens = &tokenPurger{fakePurger: tt.tokens}
+			app.oneTimeTokens = &oneTimeTokenPurger{fakePurger: tt.onetime}
+
+			app.purgeExpired(context.Background(), now, 3)
+
+			assert.Equal(t, tt.snippets.callCount(), tt.wantSnippets)
+			assert.Equal(t, tt.tokens.callCount(), 1)
+			assert.StringContains(t, infoLog.String(), tt.wantInfo)
+
+			if tt.wantError == "" {
+				assert.Equal(t, errorLog.Len(), 0)
+			} else {
+				assert.StringContains(t, errorLog.String(), tt.wantError)
+			}
+		})
+	}
+}
+
+func TestPurgeInBatchesCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	purger := &fakePurger{batches: []int{3, 3, 3}}
+
+	n, err := purgeInBatches(ctx, purger.DeleteExpired, time.Now(), 3)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+	assert.Equal(t, purger.callCount(), 0)
+}
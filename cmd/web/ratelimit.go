@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// The attemptLimiter type keeps track of attempts against a key (such as a
+// snippet ID or an email address) and blocks further attempts once there have
+// been max of them within the window. Attempts older than the window are
+// forgotten, so the key unlocks again by itself. The counts are only held in
+// memory, so they're reset whenever the application restarts.
+type attemptLimiter[K comparable] struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	clock    clock
+	attempts map[K][]time.Time
+}
+
+func newAttemptLimiter[K comparable](max int, window time.Duration, clk clock) *attemptLimiter[K] {
+	return &attemptLimiter[K]{
+		max:      max,
+		window:   window,
+		clock:    clk,
+		attempts: make(map[K][]time.Time),
+	}
+}
+
+// Reserve records an attempt against the key and reports whether it's
+// allowed. Checking and recording happen under the same lock, so requests
+// made in parallel can't all slip in before any of them has been counted.
+// Once the limit has been reached nothing more is recorded.
+func (l *attemptLimiter[K]) Reserve(key K) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	attempts := l.recent(key)
+	if len(attempts) >= l.max {
+		return false
+	}
+
+	l.attempts[key] = append(attempts, l.clock.Now())
+	return true
+}
+
+// Refund gives back an attempt which was reserved for the key, for when it
+// turned out not to be a failure (such as the right password).
+func (l *attemptLimiter[K]) Refund(key K) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	attempts := l.recent(key)
+	if len(attempts) == 0 {
+		return
+	}
+
+	if len(attempts) == 1 {
+		delete(l.attempts, key)
+		return
+	}
+
+	l.attempts[key] = attempts[:len(attempts)-1]
+}
+
+// The recent() method drops any attempts for the key which have fallen out
+// of the window, and returns the ones which are left. It must be called with
+// the mutex held.
+func (l *attemptLimiter[K]) recent(key K) []time.Time {
+	cutoff := l.clock.Now().Add(-l.window)
+
+	attempts := l.attempts[key]
+	for len(attempts) > 0 && !attempts[0].After(cutoff) {
+		attempts = attempts[1:]
+	}
+
+	if len(attempts) == 0 {
+		delete(l.attempts, key)
+		return nil
+	}
+
+	l.attempts[key] = attempts
+	return attempts
+}
+
+// Sweep forgets every key whose newest attempt fell out of the window before
+// now. Keys are otherwise only tidied up when they're used again, so without
+// this a limiter keyed by something like an email address would keep growing
+// for as long as the application runs.
+func (l *attemptLimiter[K]) Sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	cutoff := now.Add(-l.window)
+
+	for key, attempts := range l.attempts {
+		if len(attempts) == 0 || !attempts[len(attempts)-1].After(cutoff) {
+			delete(l.attempts, key)
+		}
+	}
+}
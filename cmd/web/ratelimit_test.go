@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	clk := newFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	limiter := newAttemptLimiter[int](2, time.Minute, clk)
+
+	assert.Equal(t, limiter.Reserve(1), true)
+
+	clk.Advance(30 * time.Second)
+	assert.Equal(t, limiter.Reserve(1), true)
+	assert.Equal(t, limiter.Reserve(1), false)
+
+	// Other keys aren't affected.
+	assert.Equal(t, limiter.Reserve(2), true)
+
+	// Once the first attempt is more than a minute old there's room for
+	// another one, but not two.
+	clk.Advance(31 * time.Second)
+	assert.Equal(t, limiter.Reserve(1), true)
+	assert.Equal(t, limiter.Reserve(1), false)
+
+	// A refunded attempt makes room for another.
+	limiter.Refund(1)
+	assert.Equal(t, limiter.Reserve(1), true)
+
+	// And once they've all expired the keys are forgotten completely.
+	clk.Advance(2 * time.Minute)
+	limiter.Refund(1)
+	assert.Equal(t, len(limiter.attempts), 1)
+	assert.Equal(t, limiter.Reserve(2), true)
+	limiter.Refund(2)
+	assert.Equal(t, len(limiter.attempts), 0)
+}
+
+func TestAttemptLimiterSweep(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	clk := newFakeClock(start)
+	limiter := newAttemptLimiter[string](2, time.Minute, clk)
+
+	limiter.Reserve("alice@example.com")
+	limiter.Reserve("bob@example.com")
+	clk.Advance(30 * time.Second)
+	limiter.Reserve("alice@example.com")
+
+	// Nothing has fallen out of the window yet.
+	limiter.Sweep(start.Add(50 * time.Second))
+	assert.Equal(t, len(limiter.attempts), 2)
+
+	// Bob's only attempt is now more than a minute old, but Alice still has
+	// a recent one, so her key is kept.
+	limiter.Sweep(start.Add(70 * time.Second))
+	assert.Equal(t, len(limiter.attempts), 1)
+	assert.Equal(t, len(limiter.attempts["alice@example.com"]), 2)
+
+	limiter.Sweep(start.Add(2 * time.Minute))
+	assert.Equal(t, len(limiter.attempts), 0)
+}
+
+func TestAttemptLimiterConcurrent(t *testing.T) {
+	limiter := newAttemptLimiter[int](5, time.Minute, realClock{})
+
+	// However many attempts are made at the same time, only five of them
+	// are allowed through.
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.Reserve(1) {
+				allowed.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, allowed.Load(), int32(5))
+}
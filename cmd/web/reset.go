@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"snippetbox.example.org/internal/models"
+	"snippetbox.example.org/internal/validator"
+)
+
+// Password reset links stop working after an hour.
+const passwordResetTTL = time.Hour
+
+// The message we show after a password reset has been requested. It's the
+// same whether or not the email address is registered, so that nobody can
+// use the form to find out who has an account.
+const passwordResetSent = "If that email address is registered, we've sent it a link to reset your password."
+
+type passwordForgotForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+// If RevokeTokens is set when the password is reset, all of the user's API
+// tokens are revoked too.
+type passwordResetForm struct {
+	Token                   string `form:"token"`
+	NewPassword             string `form:"new_password"`
+	NewPasswordConfirmation string `form:"new_password_confirmation"`
+	RevokeTokens            bool   `form:"revoke_tokens"`
+	validator.Validator     `form:"-"`
+}
+
+// The userPasswordForgot handler displays a form asking for the email address
+// of the account to reset.
+func (app *application) userPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = passwordForgotForm{}
+
+	app.render(w, r, http.StatusOK, "forgot.tmpl", data)
+}
+
+// The userPasswordForgotPost handler emails a password reset link to the
+// account with the given email address, if there is one. Either way the user
+// sees the same message, and because the lookup and the email both happen in
+// the background, the response takes the same time too. Requests are limited
+// for each email address, so that the form can't be used to flood someone's
+// inbox, and for each IP address.
+func (app *application) userPasswordForgotPost(w http.ResponseWriter, r *http.Request) {
+	var form passwordForgotForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "forgot.tmpl", data)
+		return
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !app.resetIPLimiter.Reserve(ip) || !app.resetEmailLimiter.Reserve(strings.ToLower(form.Email)) {
+		form.AddNonFieldErrors("Too many password reset requests. Please try again later.")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusTooManyRequests, "forgot.tmpl", data)
+		return
+	}
+
+	email := form.Email
+
+	app.background(func() {
+		user, err := app.users.GetByEmail(email)
+		if err != nil {
+			if !errors.Is(err, models.ErrNoRecord) {
+				app.errorLog.Print(err)
+			}
+			return
+		}
+
+		token, err := app.oneTimeTokens.New(user.ID, models.ScopePasswordReset, passwordResetTTL)
+		if err != nil {
+			app.errorLog.Print(err)
+			return
+		}
+
+		app.sendEmail(user.Email, "password_reset.tmpl", map[string]any{
+			"Name":    user.Name,
+			"URL":     app.publicURL + "/user/password/reset?token=" + url.QueryEscape(token),
+			"Minutes": int(passwordResetTTL.Minutes()),
+		})
+	})
+
+	app.sessionManager.Put(r.Context(), "flash", passwordResetSent)
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// The userPasswordReset handler displays the form for choosing a new
+// password. The token from the link is passed through in a hidden field, and
+// isn't used up until the form is submitted, so that mail scanners which
+// follow links don't break them.
+func (app *application) userPasswordReset(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Redirect(w, r, "/user/password/forgot", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = passwordResetForm{Token: token}
+
+	app.render(w, r, http.StatusOK, "reset.tmpl", data)
+}
+
+func (app *application) userPasswordResetPost(w http.ResponseWriter, r *http.Request) {
+	var form passwordResetForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.NewPassword), "new_password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.NewPassword, 8), "new_password", "This field must be at least 8 characters long")
+	form.CheckField(validator.NotBlank(form.NewPasswordConfirmation), "new_password_confirmation", "This field cannot be blank")
+	form.CheckField(form.NewPassword == form.NewPasswordConfirmation, "new_password_confirmation", "Passwords do not match")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "reset.tmpl", data)
+		return
+	}
+
+	// Use up the token. This is only done once the rest of the form is valid,
+	// so that a typo in the new password doesn't waste the link.
+	userID, err := app.oneTimeTokens.Consume(form.Token, models.ScopePasswordReset)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddNonFieldErrors("This password reset link is invalid or has expired")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "reset.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.users.PasswordSet(userID, form.NewPassword)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Any other reset links we've sent the user stop working now.
+	err = app.oneTimeTokens.DeleteAllForUser(userID, models.ScopePasswordReset)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Whoever reset the password isn't logged in yet, so this logs the user
+	// out everywhere, including anyone who got in with the old password.
+	err = app.destroyOtherSessions(r, userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if form.RevokeTokens {
+		err = app.tokens.DeleteAllForUser(userID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your password has been reset. Please log in.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
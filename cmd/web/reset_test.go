@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+
+	"snippetbox.example.org/internal/assert"
+	"snippetbox.example.org/internal/mailer"
+)
+
+func TestUserPasswordForgotPost(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		wantCode     int
+		wantEmails   int
+		wantLocation string
+	}{
+		{
+			name:         "Registered email",
+			email:        "alice@example.com",
+			wantCode:     http.StatusSeeOther,
+			wantEmails:   1,
+			wantLocation: "/user/login",
+		},
+		{
+			name:         "Unregistered email",
+			email:        "nobody@example.com",
+			wantCode:     http.StatusSeeOther,
+			wantEmails:   0,
+			wantLocation: "/user/login",
+		},
+		{
+			name:     "Invalid email",
+			email:    "alice@example.",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Empty email",
+			email:    "",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			_, _, body := ts.get(t, "/user/password/forgot")
+			csrfToken := extractCSRFToken(t, body)
+
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/user/password/forgot", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			// Wait for the email to be sent in the background.
+			app.wg.Wait()
+
+			messages := app.mailer.(*mailer.MemoryMailer).Messages()
+			assert.Equal(t, len(messages), tt.wantEmails)
+
+			if tt.wantEmails > 0 {
+				assert.Equal(t, messages[0].To, "alice@example.com")
+				assert.StringContains(t, messages[0].Body, "https://snippetbox.example.org/user/password/reset?token=ONETIMETOKENONETIMETOKENONETIMET")
+			}
+
+			// Registered and unregistered addresses get the same message.
+			if tt.wantCode == http.StatusSeeOther {
+				_, _, body = ts.get(t, "/user/login")
+				assert.StringContains(t, body, "If that email address is registered")
+			}
+		})
+	}
+}
+
+func TestUserPasswordForgotPostRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/password/forgot")
+	csrfToken := extractCSRFToken(t, body)
+
+	post := func(email string) (int, string) {
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("csrf_token", csrfToken)
+
+		code, _, body := ts.postForm(t, "/user/password/forgot", form)
+		return code, body
+	}
+
+	// The same address can be sent three emails an hour, however it's
+	// written.
+	for i := 0; i < 3; i++ {
+		code, _ := post("alice@example.com")
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	code, body := post("ALICE@example.com")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "Too many password reset requests")
+
+	app.wg.Wait()
+	assert.Equal(t, len(app.mailer.(*mailer.MemoryMailer).Messages()), 3)
+
+	// Other addresses can still be used, until the IP address reaches its
+	// own limit.
+	for i := 0; i < 6; i++ {
+		code, _ := post(fmt.Sprintf("user%d@example.com", i))
+		assert.Equal(t, code, http.StatusSeeOther)
+	}
+
+	code, _ = post("bob@example.com")
+	assert.Equal(t, code, http.StatusTooManyRequests)
+}
+
+func TestUserPasswordReset(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/user/password/reset?token=valid-token")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='hidden' name='token' value='valid-token'>")
+
+	// Without a token there's nothing to reset, so send the user to ask for
+	// a new link.
+	code, headers, _ := ts.get(t, "/user/password/reset")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/password/forgot")
+}
+
+func TestUserPasswordResetPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/password/reset?token=valid-token")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		token        string
+		newPassword  string
+		confirmation string
+		wantCode     int
+		wantBody     string
+	}{
+		{
+			name:         "Valid submission",
+			token:        "valid-token",
+			newPassword:  "newPa$$word",
+			confirmation: "newPa$$word",
+			wantCode:     http.StatusSeeOther,
+		},
+		{
+			name:         "Invalid token",
+			token:        "expired-token",
+			newPassword:  "newPa$$word",
+			confirmation: "newPa$$word",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantBody:     "This password reset link is invalid or has expired",
+		},
+		{
+			name:         "Short password",
+			token:        "valid-token",
+			newPassword:  "pa$$",
+			confirmation: "pa$$",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantBody:     "This field must be at least 8 characters long",
+		},
+		{
+			name:         "Mismatched confirmation",
+			token:        "valid-token",
+			newPassword:  "newPa$$word",
+			confirmation: "newPa$$wrd",
+			wantCode:     http.StatusUnprocessableEntity,
+			wantBody:     "Passwords do not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("token", tt.token)
+			form.Add("new_password", tt.newPassword)
+			form.Add("new_password_confirmation", tt.confirmation)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/user/password/reset", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusSeeOther {
+				assert.Equal(t, headers.Get("Location"), "/user/login")
+			}
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserPasswordResetPostSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Log in from one browser and keep its cookies...
+	ts.login(t)
+	otherCookies := ts.Client().Jar.Cookies(u)
+
+	// ...then reset the password from another browser.
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+
+	_, _, body := ts.get(t, "/user/password/reset?token=valid-token")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("token", "valid-token")
+	form.Add("new_password", "newPa$$word")
+	form.Add("new_password_confirmation", "newPa$$word")
+	form.Add("revoke_tokens", "true")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/password/reset", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+
+	// The first browser has been logged out.
+	jar, err = cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar.SetCookies(u, otherCookies)
+	ts.Client().Jar = jar
+
+	code, headers, _ := ts.get(t, "/account/view")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+}
@@ -20,7 +20,10 @@ func (app *application) routes() http.Handler {
 	// set a custom handler for 405 Method Not Allowed responses by setting
 	// router.NotFoundAllowed in the same way too.
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.notFound(w)
+		app.notFound(w, r)
+	})
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.clientError(w, r, http.StatusMethodNotAllowed)
 	})
 
 	// Take the ui.Files embeded filesystem and convert it to a http.FS type so
@@ -39,6 +42,14 @@ func (app *application) routes() http.Handler {
 	// Add a new GET /ping route.
 	router.HandlerFunc(http.MethodGet, "/ping", ping)
 
+	// The feeds are the same for everyone, so they don't use the session or
+	// CSRF middleware. That way they never set a cookie, and browsers and
+	// proxies are free to cache them.
+	router.HandlerFunc(http.MethodGet, "/feed.atom", app.feedAtom)
+	router.HandlerFunc(http.MethodGet, "/feed.rss", app.feedRSS)
+	router.HandlerFunc(http.MethodGet, "/user/profile/:id/feed.atom", app.feedAtom)
+	router.HandlerFunc(http.MethodGet, "/user/profile/:id/feed.rss", app.feedRSS)
+
 	// Create a new middleware chain containing the middleware specific to our
 	// dynamic application routes. For now, this chain will only contain the
 	// LoadAndSave session middleware but we'll add more to ir later.
@@ -53,12 +64,28 @@ func (app *application) routes() http.Handler {
 	// method returns a http.Handler (rather than a http.HandlerFunc) we also
 	// need to switch to registering to route using the router.Handler() method.
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
+	router.Handler(http.MethodGet, "/snippet/search", dynamic.ThenFunc(app.snippetSearch))
+	router.Handler(http.MethodGet, "/tag/:name", dynamic.ThenFunc(app.snippetTag))
+	router.Handler(http.MethodGet, "/user/profile/:id", dynamic.ThenFunc(app.userProfile))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodPost, "/snippet/unlock/:id", dynamic.ThenFunc(app.snippetUnlockPost))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/download/:id", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodGet, "/snippet/raw/:id/:name", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/download/:id/:name", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodGet, "/snippet/view/:id/history", dynamic.ThenFunc(app.snippetHistory))
+	router.Handler(http.MethodGet, "/snippet/view/:id/diff", dynamic.ThenFunc(app.snippetDiff))
 	// Add the five new routes, all of which use our 'dynamic' middleware chain.
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
 	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/password/forgot", dynamic.ThenFunc(app.userPasswordForgot))
+	router.Handler(http.MethodPost, "/user/password/forgot", dynamic.ThenFunc(app.userPasswordForgotPost))
+	router.Handler(http.MethodGet, "/user/password/reset", dynamic.ThenFunc(app.userPasswordReset))
+	router.Handler(http.MethodPost, "/user/password/reset", dynamic.ThenFunc(app.userPasswordResetPost))
+	router.Handler(http.MethodGet, "/user/verify", dynamic.ThenFunc(app.userVerify))
+	router.Handler(http.MethodPost, "/user/verify", dynamic.ThenFunc(app.userVerifyPost))
 
 	// Protected (authenticated-only) application routes, using a new "protected"
 	// middleware chain which includes the requireAuthentication middleware.
@@ -69,6 +96,43 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
 	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
+	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
+	router.Handler(http.MethodPost, "/account/verify/resend", protected.ThenFunc(app.accountVerifyResendPost))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.accountTokens))
+	router.Handler(http.MethodPost, "/account/tokens", protected.ThenFunc(app.accountTokensPost))
+	router.Handler(http.MethodPost, "/account/tokens/delete/:id", protected.ThenFunc(app.accountTokenDeletePost))
+	router.Handler(http.MethodPost, "/snippet/comment/:id", protected.ThenFunc(app.commentCreatePost))
+	router.Handler(http.MethodPost, "/comment/delete/:id", protected.ThenFunc(app.commentDeletePost))
+	router.Handler(http.MethodPost, "/snippet/star/:id", protected.ThenFunc(app.snippetStarPost))
+	router.Handler(http.MethodPost, "/snippet/unstar/:id", protected.ThenFunc(app.snippetUnstarPost))
+	router.Handler(http.MethodGet, "/user/stars", protected.ThenFunc(app.userStars))
+
+	// Routes which change an existing snippet are further restricted to the
+	// user who created it, using the requireSnippetOwner middleware.
+	owner := protected.Append(app.requireSnippetOwner)
+
+	router.Handler(http.MethodGet, "/snippet/edit/:id", owner.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", owner.ThenFunc(app.snippetEditPost))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", owner.ThenFunc(app.snippetDeletePost))
+
+	// The JSON API lives under /api/v1/ so that we can make breaking changes
+	// in a new version later without upsetting existing clients. Clients
+	// normally authenticate with a personal API token, but logged-in users
+	// can use their session too. These are the only routes which accept a
+	// token. Requests with a token don't need a CSRF token, because API
+	// clients don't have a form to read one from, but requests which use the
+	// session do.
+	api := alice.New(app.sessionManager.LoadAndSave, app.authenticate, app.noSurfForSession, app.authenticateToken)
+
+	router.Handler(http.MethodGet, "/api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:id", api.ThenFunc(app.apiSnippetGet))
+	router.Handler(http.MethodPost, "/api/v1/snippets", api.Append(app.requireAuthentication).ThenFunc(app.apiSnippetCreate))
+
+	// The plain-text paste endpoint is for command-line clients like curl,
+	// so it uses the same middleware as the API.
+	router.Handler(http.MethodPost, "/paste", api.ThenFunc(app.snippetPaste))
 
 	// Wrap the existing chain with the logRequest middleware
 	// Wrap the existing chain with the recoverPanic middleware.
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"snippetbox.example.org/internal/models"
+)
+
+// The starSnippet() method reads the snippet ID from the URL and checks that
+// the current user can see the snippet, sending an error response and
+// returning nil if not. Burn-after-reading snippets can't be starred, because
+// they'll be gone the next time anyone looks.
+func (app *application) starSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w, r)
+		return nil
+	}
+
+	snippet, err := app.snippets.Get(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return nil
+	}
+
+	if snippet.BurnAfterReading {
+		app.notFound(w, r)
+		return nil
+	}
+
+	return snippet
+}
+
+// The snippetStarPost handler stars a snippet for the current user. Starring
+// a snippet twice is harmless.
+func (app *application) snippetStarPost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.starSnippet(w, r)
+	if snippet == nil {
+		return
+	}
+
+	err := app.stars.Add(app.authenticatedUserID(r), snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// The snippetUnstarPost handler removes the current user's star from a
+// snippet.
+func (app *application) snippetUnstarPost(w http.ResponseWriter, r *http.Request) {
+	snippet := app.starSnippet(w, r)
+	if snippet == nil {
+		return
+	}
+
+	err := app.stars.Remove(app.authenticatedUserID(r), snippet.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// The userStars handler lists the snippets which the current user has
+// starred.
+func (app *application) userStars(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.stars.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+
+	app.render(w, r, http.StatusOK, "stars.tmpl", data)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestSnippetStars(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Anyone can see the star counts, but only logged-in users can star.
+	_, _, body := ts.get(t, "/")
+	assert.StringContains(t, body, "<td>&#9733; 1</td>")
+
+	_, _, body = ts.get(t, "/snippet/view/3")
+	assert.StringContains(t, body, "<span class='stars'>&#9733; 1</span>")
+
+	code, headers, _ := ts.get(t, "/user/stars")
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	ts.login(t)
+
+	// Alice has starred Bob's snippet, but not her own.
+	_, _, body = ts.get(t, "/snippet/view/3")
+	assert.StringContains(t, body, "<form action='/snippet/unstar/3' method='POST'>")
+
+	_, _, body = ts.get(t, "/snippet/view/1")
+	assert.StringContains(t, body, "<form action='/snippet/star/1' method='POST'>")
+
+	code, _, body = ts.get(t, "/user/stars")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/snippet/view/3'>")
+	assert.StringContains(t, body, "<td><a href='/user/profile/2'>Bob Smith</a></td>")
+}
+
+func TestSnippetStarPost(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Star",
+			urlPath:      "/snippet/star/1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/1",
+		},
+		{
+			name:         "Star again",
+			urlPath:      "/snippet/star/3",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/3",
+		},
+		{
+			name:         "Unstar",
+			urlPath:      "/snippet/unstar/3",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/3",
+		},
+		{
+			name:     "Burn after reading",
+			urlPath:  "/snippet/star/4",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/star/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/snippet/unstar/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"snippetbox.example.org/internal/diff"
+	"snippetbox.example.org/internal/highlight"
 	"snippetbox.example.org/internal/models"
 	"snippetbox.example.org/ui"
 )
@@ -19,14 +24,30 @@ import (
 // Add a CurrentYear field to the templateData struct.
 // Add an IsAuthenticated field to the templateData struct.
 // Add a CSRFToken field.
+// Add an AuthenticatedUserID field, so templates can tell whether the current
+// user owns the snippet being displayed.
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
+	Revisions           []*models.Revision
+	FromRevision        *models.Revision
+	ToRevision          *models.Revision
+	Hunks               []diff.Hunk
+	DiffTooLarge        bool
+	Query               string
+	Filters             models.Filters
+	Metadata            models.Metadata
+	Tokens              []*models.Token
+	NewToken            string
+	Comments            []*models.Comment
+	Starred             bool
+	User                *models.User
 }
 
 // Create a humanDate function which returns a nicely formatted string
@@ -40,11 +61,104 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// The searchTermsRX() function builds a case-insensitive regular expression
+// which matches any of the words in a search query. It returns nil if the
+// query doesn't contain any words.
+func searchTermsRX(query string) *regexp.Regexp {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	for i := range terms {
+		terms[i] = regexp.QuoteMeta(terms[i])
+	}
+
+	return regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+}
+
+// The highlightMatches() function HTML-escapes s and wraps every occurrence of the
+// words in the search query in a <mark> element. Because everything other
+// than the <mark> tags is escaped, it's safe to return the result as
+// template.HTML.
+func highlightMatches(s, query string) template.HTML {
+	rx := searchTermsRX(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(s))
+	}
+
+	var b strings.Builder
+	last := 0
+
+	for _, loc := range rx.FindAllStringIndex(s, -1) {
+		b.WriteString(template.HTMLEscapeString(s[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(s[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(s[last:]))
+
+	return template.HTML(b.String())
+}
+
+// The excerpt() function returns a short fragment of s around the first match
+// for the search query, with the matches highlighted. If there's no match we
+// use the start of s instead.
+func excerpt(s, query string) template.HTML {
+	const before, length = 40, 160
+
+	start := 0
+	if rx := searchTermsRX(query); rx != nil {
+		if loc := rx.FindStringIndex(s); loc != nil {
+			start = max(0, loc[0]-before)
+		}
+	}
+	end := min(len(s), start+length)
+
+	// Make sure that we don't cut a multi-byte character in half.
+	for start > 0 && !utf8.RuneStart(s[start]) {
+		start--
+	}
+	for end < len(s) && !utf8.RuneStart(s[end]) {
+		end++
+	}
+
+	fragment := s[start:end]
+	if start > 0 {
+		fragment = "…" + fragment
+	}
+	if end < len(s) {
+		fragment = fragment + "…"
+	}
+
+	return highlightMatches(fragment, query)
+}
+
+// The codeLine() function returns line n of a snippet's content, counting from
+// 1, or the empty string if there's no such line. It's used to quote the line
+// that a comment is about.
+func codeLine(content string, n int) string {
+	lines := splitLines(content)
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[n-1], "\r")
+}
+
 // Initialize a template.FuncMap object and store it in a global variable. This is
 // essentially a string-keyed map which acts as a lookup between the names of our
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"highlight": highlightMatches,
+	"excerpt":   excerpt,
+	// The highlightCode function does syntax highlighting of snippet content
+	// on the server, so no JavaScript is needed to show coloured code.
+	"highlightCode": highlight.Code,
+	"languages":     func() []string { return highlight.Languages },
+	"languageLabel": highlight.Label,
+	"codeLine":      codeLine,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -48,3 +49,70 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHighlightMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		query string
+		want  string
+	}{
+		{
+			name:  "Single match",
+			s:     "An old silent pond",
+			query: "pond",
+			want:  "An old silent <mark>pond</mark>",
+		},
+		{
+			name:  "Case insensitive",
+			s:     "Pond and pond",
+			query: "POND",
+			want:  "<mark>Pond</mark> and <mark>pond</mark>",
+		},
+		{
+			name:  "Multiple terms",
+			s:     "An old silent pond",
+			query: "old pond",
+			want:  "An <mark>old</mark> silent <mark>pond</mark>",
+		},
+		{
+			name:  "Escapes HTML",
+			s:     "<b>pond</b>",
+			query: "pond",
+			want:  "&lt;b&gt;<mark>pond</mark>&lt;/b&gt;",
+		},
+		{
+			name:  "Special characters in query",
+			s:     "a.b and a*b",
+			query: "a.b",
+			want:  "<mark>a.b</mark> and a*b",
+		},
+		{
+			name:  "Empty query",
+			s:     "<pond>",
+			query: "",
+			want:  "&lt;pond&gt;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(highlightMatches(tt.s, tt.query)), tt.want)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("x", 100) + " pond " + strings.Repeat("y", 200)
+
+	got := string(excerpt(long, "pond"))
+
+	// The excerpt should be trimmed at both ends and still contain the
+	// highlighted match.
+	assert.StringContains(t, got, "<mark>pond</mark>")
+	assert.Equal(t, strings.HasPrefix(got, "…"), true)
+	assert.Equal(t, strings.HasSuffix(got, "…"), true)
+
+	// Short content with no match is returned whole.
+	assert.Equal(t, string(excerpt("An old silent pond", "frog")), "An old silent pond")
+}
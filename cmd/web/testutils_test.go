@@ -10,11 +10,13 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
+	"snippetbox.example.org/internal/mailer"
 	"snippetbox.example.org/internal/models/mocks"
 )
 
@@ -55,13 +57,24 @@ func newTestApplication(t *testing.T) *application {
 	sessionManeger.Cookie.Secure = true
 
 	return &application{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
-		snippets:       &mocks.SnippetModel{},
-		users:          &mocks.UserModel{},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManeger,
+		errorLog:            log.New(io.Discard, "", 0),
+		infoLog:             log.New(io.Discard, "", 0),
+		snippets:            &mocks.SnippetModel{},
+		users:               &mocks.UserModel{},
+		sessions:            &mocks.SessionModel{},
+		tokens:              &mocks.TokenModel{},
+		comments:            &mocks.CommentModel{},
+		stars:               &mocks.StarModel{},
+		oneTimeTokens:       &mocks.OneTimeTokenModel{},
+		mailer:              &mailer.MemoryMailer{},
+		publicURL:           "https://snippetbox.example.org",
+		templateCache:       templateCache,
+		formDecoder:         formDecoder,
+		sessionManager:      sessionManeger,
+		unlockLimiter:       newAttemptLimiter[int](5, 15*time.Minute, realClock{}),
+		resetEmailLimiter:   newAttemptLimiter[string](3, time.Hour, realClock{}),
+		resetIPLimiter:      newAttemptLimiter[string](10, time.Hour, realClock{}),
+		verifyResendLimiter: newAttemptLimiter[int](3, time.Hour, realClock{}),
 	}
 }
 
@@ -137,3 +150,57 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, strin
 
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// Implement a login() method which logs the test server client in as the
+// mocked user "alice@example.com", so that we can make requests to the
+// protected routes. It returns a fresh CSRF token which can be used in any
+// subsequent POST requests.
+func (ts *testServer) login(t *testing.T) string {
+	return ts.loginAs(t, "alice@example.com")
+}
+
+// The loginAs() method logs in as any of the mocked users, who all have the
+// password "pa$$word".
+func (ts *testServer) loginAs(t *testing.T, email string) string {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+
+	return csrfToken
+}
+
+// Create a postJSON method for sending POST requests with a JSON body to the
+// test server. Requests which use the session need a CSRF token, which is
+// sent in the X-CSRF-Token header if it isn't empty.
+func (ts *testServer) postJSON(t *testing.T, urlPath string, body string, csrfToken string) (int, http.Header, string) {
+	req, err := http.NewRequest(http.MethodPost, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if csrfToken != "" {
+		req.Header.Set("X-CSRF-Token", csrfToken)
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	respBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(respBody)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"snippetbox.example.org/internal/models"
+	"snippetbox.example.org/internal/validator"
+)
+
+// Verification links stop working after three days.
+const verificationTTL = 3 * 24 * time.Hour
+
+type verifyForm struct {
+	Token               string `form:"token"`
+	validator.Validator `form:"-"`
+}
+
+// The sendVerificationEmail() method creates a verification token for the
+// user, and emails them a link to confirm their address.
+func (app *application) sendVerificationEmail(userID int, name, email string) error {
+	token, err := app.oneTimeTokens.New(userID, models.ScopeVerification, verificationTTL)
+	if err != nil {
+		return err
+	}
+
+	app.sendEmail(email, "verification.tmpl", map[string]any{
+		"Name": name,
+		"URL":  app.publicURL + "/user/verify?token=" + url.QueryEscape(token),
+		"Days": int(verificationTTL.Hours() / 24),
+	})
+
+	return nil
+}
+
+// The checkVisibility() method adds a validation error if the current user
+// wants a snippet to be public but hasn't confirmed their email address yet.
+// Unverified users can still create unlisted and private snippets.
+func (app *application) checkVisibility(r *http.Request, v *validator.Validator, visibility string) error {
+	if visibility != models.VisibilityPublic {
+		return nil
+	}
+
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		return err
+	}
+
+	v.CheckField(user.Verified, "visibility", "You must confirm your email address before creating public snippets")
+	return nil
+}
+
+// The defaultVisibility() method returns the visibility to give a new snippet
+// when the user doesn't choose one. That's public, unless the user hasn't
+// confirmed their email address, in which case it's unlisted so that the
+// snippet isn't rejected by checkVisibility().
+func (app *application) defaultVisibility(r *http.Request) (string, error) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		return "", err
+	}
+
+	if !user.Verified {
+		return models.VisibilityUnlisted, nil
+	}
+	return models.VisibilityPublic, nil
+}
+
+// The userVerify handler shows a button to confirm the email address. Like
+// password reset links, the token isn't used up until the form is submitted,
+// so that mail scanners which follow links don't use it up. Users don't have
+// to be logged in, because they might open the link in a different browser.
+func (app *application) userVerify(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		app.notFound(w, r)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = verifyForm{Token: token}
+
+	app.render(w, r, http.StatusOK, "verify.tmpl", data)
+}
+
+func (app *application) userVerifyPost(w http.ResponseWriter, r *http.Request) {
+	var form verifyForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := app.oneTimeTokens.Consume(form.Token, models.ScopeVerification)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddNonFieldErrors("This confirmation link is invalid or has expired")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "verify.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	err = app.users.Verify(userID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Any other confirmation links we've sent the user aren't needed now.
+	err = app.oneTimeTokens.DeleteAllForUser(userID, models.ScopeVerification)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your email address has been confirmed!")
+
+	if app.isAuthenticated(r) {
+		http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+	} else {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+	}
+}
+
+// The accountVerifyResendPost handler sends the current user a new
+// confirmation link, in case the first one got lost or expired. Anyone can
+// sign up with someone else's email address, so the number of links we'll
+// resend to each user is limited, to stop the form being used to flood an
+// inbox.
+func (app *application) accountVerifyResendPost(w http.ResponseWriter, r *http.Request) {
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if !user.Verified {
+		if !app.verifyResendLimiter.Reserve(user.ID) {
+			app.sessionManager.Put(r.Context(), "flash", "We've already sent you several confirmation links. Please try again later.")
+			http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+			return
+		}
+
+		err = app.sendVerificationEmail(user.ID, user.Name, user.Email)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.sessionManager.Put(r.Context(), "flash", "We've emailed you a new confirmation link.")
+	}
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"snippetbox.example.org/internal/assert"
+	"snippetbox.example.org/internal/mailer"
+)
+
+func TestUserSignupVerificationEmail(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/user/signup")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("name", "Dave")
+	form.Add("email", "dave@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", csrfToken)
+
+	code, headers, _ := ts.postForm(t, "/user/signup", form)
+	assert.Equal(t, code, http.StatusSeeOther)
+	assert.Equal(t, headers.Get("Location"), "/user/login")
+
+	// Wait for the email to be sent in the background.
+	app.wg.Wait()
+
+	messages := app.mailer.(*mailer.MemoryMailer).Messages()
+	assert.Equal(t, len(messages), 1)
+	assert.Equal(t, messages[0].To, "dave@example.com")
+	assert.Equal(t, messages[0].Subject, "Confirm your Snippetbox email address")
+	assert.StringContains(t, messages[0].Body, "https://snippetbox.example.org/user/verify?token=ONETIMETOKENONETIMETOKENONETIMET")
+}
+
+func TestUserVerify(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/user/verify?token=valid-token")
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<input type='hidden' name='token' value='valid-token'>")
+
+	code, _, _ = ts.get(t, "/user/verify")
+	assert.Equal(t, code, http.StatusNotFound)
+
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		token        string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Valid token",
+			token:        "valid-token",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/user/login",
+		},
+		{
+			name:     "Invalid token",
+			token:    "expired-token",
+			wantCode: http.StatusUnprocessableEntity,
+			wantBody: "This confirmation link is invalid or has expired",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("token", tt.token)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/user/verify", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAccountVerifyResendPost(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		wantEmails int
+	}{
+		{
+			name:       "Unverified",
+			email:      "carol@example.com",
+			wantEmails: 1,
+		},
+		{
+			name:       "Already verified",
+			email:      "alice@example.com",
+			wantEmails: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			csrfToken := ts.loginAs(t, tt.email)
+
+			_, _, body := ts.get(t, "/account/view")
+			assert.Equal(t, strings.Contains(body, "(unconfirmed)"), tt.wantEmails > 0)
+
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, "/account/verify/resend", form)
+			assert.Equal(t, code, http.StatusSeeOther)
+			assert.Equal(t, headers.Get("Location"), "/account/view")
+
+			app.wg.Wait()
+
+			messages := app.mailer.(*mailer.MemoryMailer).Messages()
+			assert.Equal(t, len(messages), tt.wantEmails)
+		})
+	}
+}
+
+func TestAccountVerifyResendPostRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.loginAs(t, "carol@example.com")
+
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+
+	// Three links can be resent in an hour, and after that the user is told
+	// to wait.
+	for i := 0; i < 4; i++ {
+		code, headers, _ := ts.postForm(t, "/account/verify/resend", form)
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/account/view")
+	}
+
+	_, _, body := ts.get(t, "/account/view")
+	assert.StringContains(t, body, "Please try again later.")
+
+	app.wg.Wait()
+
+	messages := app.mailer.(*mailer.MemoryMailer).Messages()
+	assert.Equal(t, len(messages), 3)
+}
+
+func TestUnverifiedPublicSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.loginAs(t, "carol@example.com")
+
+	// The create form starts unverified users off with an unlisted snippet.
+	_, _, body := ts.get(t, "/snippet/create")
+	assert.StringContains(t, body, "<input type='radio' name='visibility' value='unlisted' checked>")
+
+	tests := []struct {
+		name       string
+		visibility string
+		wantCode   int
+	}{
+		{
+			name:       "Public",
+			visibility: "public",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Unlisted",
+			visibility: "unlisted",
+			wantCode:   http.StatusSeeOther,
+		},
+		{
+			name:       "Private",
+			visibility: "private",
+			wantCode:   http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "O snail")
+			form.Add("content", "O snail, climb Mount Fuji")
+			form.Add("language", "plaintext")
+			form.Add("visibility", tt.visibility)
+			form.Add("expires", "7d")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantCode == http.StatusUnprocessableEntity {
+				assert.StringContains(t, body, "You must confirm your email address before creating public snippets")
+			}
+		})
+	}
+}
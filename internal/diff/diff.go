@@ -0,0 +1,368 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Op describes what happened to a line when going from the old text to the
+// new text.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// String returns a lower-case name for the operation, which is handy for use
+// as a CSS class name in templates.
+func (op Op) String() string {
+	switch op {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	default:
+		return "equal"
+	}
+}
+
+// Line is a single line in a diff, along with the operation that applies to
+// it.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Prefix returns the character which is used in front of the line in a
+// unified diff: a space for unchanged lines, "-" for deletions and "+" for
+// insertions.
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	default:
+		return " "
+	}
+}
+
+// Hunk is a group of changed lines together with some surrounding context,
+// in the same shape as a hunk in the output of `diff -u`. Line numbers are
+// 1-based, like they are in a unified diff header.
+type Hunk struct {
+	FromLine  int
+	FromCount int
+	ToLine    int
+	ToCount   int
+	Lines     []Line
+}
+
+// Header returns the "@@ -1,3 +1,4 @@" line for the hunk. Like GNU diff, an
+// empty range is reported as starting on the line before the change.
+func (h Hunk) Header() string {
+	from, to := h.FromLine, h.ToLine
+	if h.FromCount == 0 {
+		from--
+	}
+	if h.ToCount == 0 {
+		to--
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", from, h.FromCount, to, h.ToCount)
+}
+
+// MaxLines is the largest number of lines, counting both texts together,
+// which Unified() will compare. Diffing takes time proportional to the number
+// of lines multiplied by the number of changes, so anything bigger is
+// refused with ErrTooLarge rather than tying up the server.
+const MaxLines = 10000
+
+// ErrTooLarge is returned by Unified() when the texts have more than MaxLines
+// lines between them.
+var ErrTooLarge = errors.New("diff: too many lines to compare")
+
+// Lines() compares a and b line-by-line and returns the full list of equal,
+// deleted and inserted lines needed to turn a into b. It uses Myers' O(ND)
+// algorithm in its linear space form, so memory use only grows with the
+// length of the texts, while the time taken grows with the length multiplied
+// by the number of differences. Callers who can't trust the size of their
+// input should use Unified(), which enforces MaxLines.
+func Lines(a, b string) []Line {
+	x, y := split(a), split(b)
+
+	// Comparing small integers is much cheaper than comparing strings, so
+	// give each distinct line a number and diff those instead.
+	ids := make(map[string]int)
+	number := func(lines []string) []int {
+		ns := make([]int, len(lines))
+		for i, l := range lines {
+			id, ok := ids[l]
+			if !ok {
+				id = len(ids)
+				ids[l] = id
+			}
+			ns[i] = id
+		}
+		return ns
+	}
+
+	c := comparison{x: x, y: y, lines: make([]Line, 0, len(x)+len(y))}
+	c.compare(number(x), number(y), 0, 0)
+
+	return tidy(c.lines)
+}
+
+// The comparison struct collects the output of Lines() as the recursive
+// compare() method works through the texts from start to end.
+type comparison struct {
+	x, y  []string
+	lines []Line
+}
+
+// The compare() method appends the lines needed to turn a into b, which
+// start at offsets i and j of the original texts. After trimming any common
+// prefix and suffix it finds a point on an optimal edit path with bisect()
+// and handles each side of it separately.
+func (c *comparison) compare(a, b []int, i, j int) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		c.lines = append(c.lines, Line{Equal, c.x[i]})
+		a, b = a[1:], b[1:]
+		i++
+		j++
+	}
+
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	a, b = a[:len(a)-n], b[:len(b)-n]
+	suffix := i + len(a)
+
+	if len(a) > 0 && len(b) > 0 {
+		if u, v, ok := bisect(a, b); ok {
+			c.compare(a[:u], b[:v], i, j)
+			c.compare(a[u:], b[v:], i+u, j+v)
+			a, b = nil, nil
+		}
+	}
+
+	// Anything left over has nothing in common, so it's all deleted and
+	// inserted.
+	for k := range a {
+		c.lines = append(c.lines, Line{Delete, c.x[i+k]})
+	}
+	for k := range b {
+		c.lines = append(c.lines, Line{Insert, c.y[j+k]})
+	}
+
+	for k := 0; k < n; k++ {
+		c.lines = append(c.lines, Line{Equal, c.x[suffix+k]})
+	}
+}
+
+// The bisect() function finds the "middle snake" of an optimal edit path
+// from a to b, by running Myers' greedy search forwards from the start and
+// backwards from the end at the same time until the two meet. It returns the
+// point where they met, which splits the problem into two smaller ones, or
+// false if a and b have no lines in common at all.
+func bisect(a, b []int) (int, int, bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+
+	// vf[offset+k] holds the furthest x reached on diagonal k (where k is
+	// x-y) by the forward search, and vb the same for the backward search,
+	// measured from the end of the texts. -1 means not reached yet.
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	// If the difference in length is odd the two searches will meet during
+	// a forward step, and otherwise during a backward step.
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonals which have run off the edge of the grid are dropped from
+	// the ends of the range searched at each step.
+	fStart, fEnd, bStart, bEnd := 0, 0, 0, 0
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				kb := offset + delta - k
+				if kb >= 0 && kb < len(vb) && vb[kb] != -1 && x >= n-vb[kb] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[offset+k-1] < vb[offset+k+1]) {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				kf := offset + delta - k
+				if kf >= 0 && kf < len(vf) && vf[kf] != -1 {
+					xf := vf[kf]
+					if xf >= n-x {
+						return xf, offset + xf - kf, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// The tidy() function reorders each run of changed lines so that all the
+// deletions come before the insertions, which reads more naturally than the
+// interleaved order the search can produce.
+func tidy(lines []Line) []Line {
+	out := make([]Line, 0, len(lines))
+	for k := 0; k < len(lines); {
+		if lines[k].Op == Equal {
+			out = append(out, lines[k])
+			k++
+			continue
+		}
+
+		end := k
+		for end < len(lines) && lines[end].Op != Equal {
+			end++
+		}
+		for _, l := range lines[k:end] {
+			if l.Op == Delete {
+				out = append(out, l)
+			}
+		}
+		for _, l := range lines[k:end] {
+			if l.Op == Insert {
+				out = append(out, l)
+			}
+		}
+		k = end
+	}
+	return out
+}
+
+// Unified() returns the differences between a and b grouped into hunks, with
+// up to n unchanged lines of context around each change. If a and b are
+// identical it returns an empty slice. If the texts have more than MaxLines
+// lines between them it returns ErrTooLarge.
+func Unified(a, b string, n int) ([]Hunk, error) {
+	if len(split(a))+len(split(b)) > MaxLines {
+		return nil, ErrTooLarge
+	}
+
+	lines := Lines(a, b)
+
+	hunks := []Hunk{}
+	var h *Hunk
+
+	// Track the current line number in both the old and new text as we go.
+	from, to := 1, 1
+
+	for k, l := range lines {
+		if l.Op == Equal {
+			// An unchanged line is only interesting if it's within n lines of
+			// a change, either before or after it.
+			if !changedWithin(lines, k, n) {
+				if h != nil {
+					hunks = append(hunks, *h)
+					h = nil
+				}
+				from++
+				to++
+				continue
+			}
+		}
+
+		if h == nil {
+			h = &Hunk{FromLine: from, ToLine: to}
+		}
+		h.Lines = append(h.Lines, l)
+
+		switch l.Op {
+		case Equal:
+			h.FromCount++
+			h.ToCount++
+			from++
+			to++
+		case Delete:
+			h.FromCount++
+			from++
+		case Insert:
+			h.ToCount++
+			to++
+		}
+	}
+
+	if h != nil {
+		hunks = append(hunks, *h)
+	}
+
+	return hunks, nil
+}
+
+// changedWithin() reports whether there is a deleted or inserted line within
+// n positions of lines[k].
+func changedWithin(lines []Line, k, n int) bool {
+	for i := max(0, k-n); i <= min(len(lines)-1, k+n); i++ {
+		if lines[i].Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// split() breaks the text into lines, ignoring a single trailing newline and
+// normalizing Windows line endings (browsers submit textarea contents with
+// \r\n).
+func split(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
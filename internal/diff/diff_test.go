@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+// format() renders the hunks in the same way as `diff -u` would, which makes
+// the expected values in the tests below easy to read.
+func format(hunks []Hunk) string {
+	var b strings.Builder
+	for _, h := range hunks {
+		b.WriteString(h.Header() + "\n")
+		for _, l := range h.Lines {
+			b.WriteString(l.Prefix() + l.Text + "\n")
+		}
+	}
+	return b.String()
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo\nthree",
+			b:    "one\ntwo\nthree",
+			want: "",
+		},
+		{
+			name: "Changed line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: "@@ -1,3 +1,3 @@\n one\n-two\n+2\n three\n",
+		},
+		{
+			name: "Appended line",
+			a:    "one\ntwo",
+			b:    "one\ntwo\nthree",
+			want: "@@ -2,1 +2,2 @@\n two\n+three\n",
+		},
+		{
+			name: "From empty",
+			a:    "",
+			b:    "one",
+			want: "@@ -0,0 +1,1 @@\n+one\n",
+		},
+		{
+			name: "CRLF line endings",
+			a:    "one\r\ntwo\r\n",
+			b:    "one\ntwo\n",
+			want: "",
+		},
+		{
+			name: "Separate hunks",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\ni\nj",
+			b:    "A\nb\nc\nd\ne\nf\ng\nh\ni\nJ",
+			want: "@@ -1,2 +1,2 @@\n-a\n+A\n b\n@@ -9,2 +9,2 @@\n i\n-j\n+J\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hunks, err := Unified(tt.a, tt.b, 1)
+			assert.NilError(t, err)
+			assert.Equal(t, format(hunks), tt.want)
+		})
+	}
+}
+
+func TestUnifiedLarge(t *testing.T) {
+	// Two completely different texts are the worst case for the diff, but
+	// it should still finish quickly right up to the limit.
+	a := strings.Repeat("a\n", MaxLines/2)
+	b := strings.Repeat("b\n", MaxLines/2)
+
+	start := time.Now()
+	hunks, err := Unified(a, b, 3)
+	assert.NilError(t, err)
+	assert.Equal(t, len(hunks), 1)
+	assert.Equal(t, len(hunks[0].Lines), MaxLines)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %s", elapsed)
+	}
+
+	_, err = Unified(a+"a\n", b, 3)
+	assert.Equal(t, err, ErrTooLarge)
+}
@@ -0,0 +1,237 @@
+package highlight
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// Languages holds the allow-list of languages which snippets can be written
+// in, in the order that they should be offered to users. Anything else must
+// be rejected before it is stored.
+var Languages = []string{"plaintext", "go", "sql", "yaml", "json"}
+
+// labels maps each language to the human-friendly name we show in the UI.
+var labels = map[string]string{
+	"plaintext": "Plain text",
+	"go":        "Go",
+	"sql":       "SQL",
+	"yaml":      "YAML",
+	"json":      "JSON",
+}
+
+// extensions maps each language to the file extension used when a snippet is
+// downloaded.
+var extensions = map[string]string{
+	"plaintext": ".txt",
+	"go":        ".go",
+	"sql":       ".sql",
+	"yaml":      ".yaml",
+	"json":      ".json",
+}
+
+// Extension returns the file extension for a language, including the leading
+// dot. Languages we don't know about are treated as plain text.
+func Extension(language string) string {
+	if ext, ok := extensions[language]; ok {
+		return ext
+	}
+	return ".txt"
+}
+
+// Label returns the display name for a language, or the language itself if
+// we don't have a nicer name for it.
+func Label(language string) string {
+	if label, ok := labels[language]; ok {
+		return label
+	}
+	return language
+}
+
+// A rule matches a single kind of token at the start of the remaining input.
+// Every pattern is anchored with ^ when the lexer is built. If keywords is
+// set, the rule matches identifiers and the class is looked up in the map
+// instead, with unknown identifiers left unstyled. If notAfter is set, the
+// rule is skipped when the previous byte matches it.
+type rule struct {
+	class    string
+	rx       *regexp.Regexp
+	keywords map[string]string
+	notAfter *regexp.Regexp
+}
+
+// A lexer is the ordered list of rules for a language. The first rule which
+// matches wins, so more specific rules need to come first.
+//
+// Code() tries the rules at every position which isn't already part of a
+// token, so a rule which can read a long way ahead and then fail would make
+// highlighting take quadratic time. To avoid that, comments and strings
+// which are never closed match up to the end of the line or input, in the
+// same way as an editor would show them, and the YAML key rule isn't tried
+// again in the middle of a word which it has already failed to match.
+type lexer struct {
+	rules      []rule
+	ignoreCase bool
+}
+
+func newRule(class, pattern string) rule {
+	return rule{class: class, rx: regexp.MustCompile(`^(?:` + pattern + `)`)}
+}
+
+func newKeywordRule(pattern string, keywords map[string]string) rule {
+	return rule{rx: regexp.MustCompile(`^(?:` + pattern + `)`), keywords: keywords}
+}
+
+// newKeyRule returns a rule for keys which start with a run of the given
+// characters, and which therefore can't start in the middle of one.
+func newKeyRule(pattern, chars string) rule {
+	r := newRule("key", pattern)
+	r.notAfter = regexp.MustCompile(`^` + chars + `$`)
+	return r
+}
+
+// words builds a keyword map from a space separated list of words, all of
+// which are given the same class.
+func words(class, list string, into map[string]string) map[string]string {
+	if into == nil {
+		into = map[string]string{}
+	}
+	for _, w := range strings.Fields(list) {
+		into[w] = class
+	}
+	return into
+}
+
+var lexers = map[string]*lexer{
+	"go": {
+		rules: []rule{
+			newRule("comment", `//[^\n]*|/\*[\s\S]*?(?:\*/|$)`),
+			newRule("string", `"(?:[^"\\\n]|\\.)*"?|`+"`[^`]*`?"+`|'(?:[^'\\\n]|\\.)*'?`),
+			newRule("number", `0[xX][0-9a-fA-F_]+|\d[\d_]*(?:\.\d+)?(?:[eE][+-]?\d+)?`),
+			newKeywordRule(`[A-Za-z_]\w*`, words("builtin",
+				"bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr any comparable "+
+					"append cap clear close complex copy delete imag len make max min new panic print println real recover true false iota nil",
+				words("keyword",
+					"break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var",
+					nil))),
+		},
+	},
+	"sql": {
+		ignoreCase: true,
+		rules: []rule{
+			newRule("comment", `--[^\n]*|#[^\n]*|/\*[\s\S]*?(?:\*/|$)`),
+			newRule("string", `'(?:[^'\\]|''|\\.)*'?|"(?:[^"\\]|""|\\.)*"?`),
+			newRule("name", "`[^`]*`?"),
+			newRule("number", `\d+(?:\.\d+)?`),
+			newKeywordRule(`[A-Za-z_]\w*`, words("builtin",
+				"int integer bigint smallint tinyint decimal numeric float double char varchar text blob date datetime timestamp time boolean bool json enum "+
+					"count sum avg min max now coalesce concat lower upper length substring utc_timestamp date_add date_sub interval",
+				words("keyword",
+					"select from where and or not null is in like between exists as on join inner left right outer cross full using group by having order asc desc limit offset "+
+						"insert into values update set delete create table index view drop alter add column constraint primary key foreign references unique default "+
+						"distinct union all case when then else end with begin commit rollback transaction if auto_increment cascade true false",
+					nil))),
+		},
+	},
+	"yaml": {
+		rules: []rule{
+			newRule("comment", `#[^\n]*`),
+			newKeyRule(`[\w.\-/]+[ \t]*:(?:[ \t]|\n|$)`, `[\w.\-/]`),
+			newRule("string", `"(?:[^"\\\n]|\\.)*"?|'(?:[^'\n]|'')*'?`),
+			newRule("number", `-?\d+(?:\.\d+)?\b`),
+			newKeywordRule(`[A-Za-z_][\w\-]*`, words("keyword", "true false yes no on off null", nil)),
+		},
+	},
+	"json": {
+		rules: []rule{
+			newRule("key", `"(?:[^"\\\n]|\\.)*"[ \t]*:`),
+			newRule("string", `"(?:[^"\\\n]|\\.)*"?`),
+			newRule("number", `-?\d+(?:\.\d+)?(?:[eE][+-]?\d+)?`),
+			newKeywordRule(`[A-Za-z_]\w*`, words("keyword", "true false null", nil)),
+		},
+	},
+}
+
+// Code returns the source code as HTML, with each recognised token wrapped in
+// a <span class="tok-..."> element. All of the text from the snippet is
+// passed through template.HTMLEscapeString() and the only markup we add
+// ourselves is the span tags with fixed class names, so the result is safe to
+// use as template.HTML. If the language isn't one we know how to highlight,
+// the code is simply escaped.
+func Code(code, language string) template.HTML {
+	lx, ok := lexers[language]
+	if !ok {
+		return template.HTML(template.HTMLEscapeString(code))
+	}
+
+	var b strings.Builder
+
+	// plain marks the start of a run of unstyled text which hasn't been
+	// written out yet. We batch these up rather than escaping one character
+	// at a time.
+	plain := 0
+	pos := 0
+
+	for pos < len(code) {
+		class, n := lx.match(code, pos)
+		if n == 0 {
+			// Nothing matched, so skip over this byte. If it's the start of
+			// an identifier-like word, skip the whole word so that we don't
+			// match a number or keyword in the middle of it.
+			if loc := wordRX.FindStringIndex(code[pos:]); loc != nil {
+				pos += loc[1]
+			} else {
+				pos++
+			}
+			continue
+		}
+
+		b.WriteString(template.HTMLEscapeString(code[plain:pos]))
+
+		if class == "" {
+			b.WriteString(template.HTMLEscapeString(code[pos : pos+n]))
+		} else {
+			b.WriteString(`<span class="tok-` + class + `">`)
+			b.WriteString(template.HTMLEscapeString(code[pos : pos+n]))
+			b.WriteString(`</span>`)
+		}
+
+		pos += n
+		plain = pos
+	}
+
+	b.WriteString(template.HTMLEscapeString(code[plain:]))
+
+	return template.HTML(b.String())
+}
+
+var wordRX = regexp.MustCompile(`^\w+`)
+
+// match tries each rule in turn against code starting at pos, returning the
+// class and length of the first match. A zero length means nothing matched.
+func (lx *lexer) match(code string, pos int) (string, int) {
+	s := code[pos:]
+
+	for _, r := range lx.rules {
+		if r.notAfter != nil && pos > 0 && r.notAfter.MatchString(code[pos-1:pos]) {
+			continue
+		}
+
+		loc := r.rx.FindStringIndex(s)
+		if loc == nil || loc[1] == 0 {
+			continue
+		}
+
+		if r.keywords == nil {
+			return r.class, loc[1]
+		}
+
+		word := s[:loc[1]]
+		if lx.ignoreCase {
+			word = strings.ToLower(word)
+		}
+		return r.keywords[word], loc[1]
+	}
+
+	return "", 0
+}
@@ -0,0 +1,127 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		language string
+		want     string
+	}{
+		{
+			name:     "Go",
+			code:     `func main() { fmt.Println("hi", 42) } // done`,
+			language: "go",
+			want:     `<span class="tok-keyword">func</span> main() { fmt.Println(<span class="tok-string">&#34;hi&#34;</span>, <span class="tok-number">42</span>) } <span class="tok-comment">// done</span>`,
+		},
+		{
+			name:     "Go identifiers containing keywords",
+			code:     `format := x1`,
+			language: "go",
+			want:     `format := x1`,
+		},
+		{
+			name:     "SQL is case insensitive",
+			code:     `SELECT id FROM snippets WHERE title = 'it''s'`,
+			language: "sql",
+			want:     `<span class="tok-keyword">SELECT</span> id <span class="tok-keyword">FROM</span> snippets <span class="tok-keyword">WHERE</span> title = <span class="tok-string">&#39;it&#39;&#39;s&#39;</span>`,
+		},
+		{
+			name:     "YAML",
+			code:     "name: web # the service\nreplicas: 3\nenabled: true",
+			language: "yaml",
+			want:     "<span class=\"tok-key\">name: </span>web <span class=\"tok-comment\"># the service</span>\n<span class=\"tok-key\">replicas: </span><span class=\"tok-number\">3</span>\n<span class=\"tok-key\">enabled: </span><span class=\"tok-keyword\">true</span>",
+		},
+		{
+			name:     "JSON",
+			code:     `{"id": 1, "ok": null}`,
+			language: "json",
+			want:     `{<span class="tok-key">&#34;id&#34;:</span> <span class="tok-number">1</span>, <span class="tok-key">&#34;ok&#34;:</span> <span class="tok-keyword">null</span>}`,
+		},
+		{
+			name:     "Escapes HTML inside tokens",
+			code:     `"<script>alert(1)</script>"`,
+			language: "go",
+			want:     `<span class="tok-string">&#34;&lt;script&gt;alert(1)&lt;/script&gt;&#34;</span>`,
+		},
+		{
+			name:     "Escapes HTML outside tokens",
+			code:     `a <b> c`,
+			language: "go",
+			want:     `a &lt;b&gt; c`,
+		},
+		{
+			name:     "Unterminated comment",
+			code:     "x /* never closed\ny := 1",
+			language: "go",
+			want:     "x <span class=\"tok-comment\">/* never closed\ny := 1</span>",
+		},
+		{
+			name:     "Unterminated string",
+			code:     "s := \"open\nt := 2",
+			language: "go",
+			want:     "s := <span class=\"tok-string\">&#34;open</span>\nt := <span class=\"tok-number\">2</span>",
+		},
+		{
+			name:     "Plain text",
+			code:     `if x < 1 { return "y" }`,
+			language: "plaintext",
+			want:     `if x &lt; 1 { return &#34;y&#34; }`,
+		},
+		{
+			name:     "Unknown language",
+			code:     `<b>`,
+			language: "cobol",
+			want:     `&lt;b&gt;`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(Code(tt.code, tt.language)), tt.want)
+		})
+	}
+}
+
+func TestCodeLargeInput(t *testing.T) {
+	// Each of these used to be retried at every position until the end of
+	// the input, which took quadratic time.
+	tests := []struct {
+		name     string
+		code     string
+		language string
+	}{
+		{name: "Unterminated comments", code: strings.Repeat("/* ", 50000), language: "go"},
+		{name: "Unterminated strings", code: strings.Repeat(`"\`, 50000), language: "go"},
+		{name: "Unterminated SQL strings", code: strings.Repeat("'a\n", 50000), language: "sql"},
+		{name: "YAML words without keys", code: strings.Repeat("a.", 50000), language: "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			Code(tt.code, tt.language)
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, Label("sql"), "SQL")
+	assert.Equal(t, Label("cobol"), "cobol")
+}
+
+func TestExtension(t *testing.T) {
+	assert.Equal(t, Extension("go"), ".go")
+	assert.Equal(t, Extension("plaintext"), ".txt")
+	assert.Equal(t, Extension("cobol"), ".txt")
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"text/template"
+)
+
+// The email templates live in the templates directory, and are embedded in
+// the binary in the same way as the HTML templates in the ui package. Each
+// one defines a "subject" and a "plainBody" template.
+//
+//go:embed "templates"
+var templateFS embed.FS
+
+// A Message is a rendered email, ready to be sent.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// The Mailer interface is implemented by everything which can send emails.
+// The application only depends on this interface, so we can use an SMTP
+// server in production, write emails to the log in development, and keep
+// them in memory in the tests.
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// The NewMessage() function renders the named email template with the given
+// dynamic data, and returns a Message addressed to the recipient.
+func NewMessage(recipient, templateFile string, data any) (*Message, error) {
+	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	if err != nil {
+		return nil, err
+	}
+
+	body := new(bytes.Buffer)
+	err = tmpl.ExecuteTemplate(body, "plainBody", data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		To:      recipient,
+		Subject: subject.String(),
+		Body:    body.String(),
+	}
+
+	return msg, nil
+}
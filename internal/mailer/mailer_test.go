@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestNewMessage(t *testing.T) {
+	data := map[string]any{
+		"Name":    "Alice Jones",
+		"URL":     "https://localhost:4000/user/password/reset?token=abc",
+		"Minutes": 60,
+	}
+
+	msg, err := NewMessage("alice@example.com", "password_reset.tmpl", data)
+	assert.NilError(t, err)
+
+	assert.Equal(t, msg.To, "alice@example.com")
+	assert.Equal(t, msg.Subject, "Reset your Snippetbox password")
+	assert.StringContains(t, msg.Body, "Hi Alice Jones,")
+	assert.StringContains(t, msg.Body, "https://localhost:4000/user/password/reset?token=abc")
+	assert.StringContains(t, msg.Body, "expires in 60 minutes")
+
+	_, err = NewMessage("alice@example.com", "missing.tmpl", data)
+	assert.Equal(t, err != nil, true)
+}
+
+func TestFormat(t *testing.T) {
+	from := &mail.Address{Name: "Snippetbox", Address: "no-reply@snippetbox.example.org"}
+	to := &mail.Address{Address: "alice@example.com"}
+	msg := &Message{
+		To:      "alice@example.com",
+		Subject: "Héllo\r\nBcc: mallory@example.com",
+		Body:    "Line one\nLine two\n",
+	}
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	got := string(format(from, to, msg, date))
+
+	assert.StringContains(t, got, "From: \"Snippetbox\" <no-reply@snippetbox.example.org>\r\n")
+	assert.StringContains(t, got, "To: <alice@example.com>\r\n")
+	assert.StringContains(t, got, "Date: Fri, 01 Mar 2024 12:00:00 +0000\r\n")
+	assert.StringContains(t, got, "\r\n\r\nLine one\r\nLine two\r\n")
+
+	// The subject is encoded, so a newline in it can't start a new header.
+	assert.StringContains(t, got, "Subject: =?utf-8?q?")
+	assert.Equal(t, strings.Contains(got, "\r\nBcc:"), false)
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := &MemoryMailer{}
+
+	assert.Equal(t, len(m.Messages()), 0)
+
+	assert.NilError(t, m.Send(&Message{To: "alice@example.com", Subject: "One"}))
+	assert.NilError(t, m.Send(&Message{To: "bob@example.com", Subject: "Two"}))
+
+	messages := m.Messages()
+	assert.Equal(t, len(messages), 2)
+	assert.Equal(t, messages[0].Subject, "One")
+	assert.Equal(t, messages[1].To, "bob@example.com")
+}
+
+func TestVerificationMessage(t *testing.T) {
+	data := map[string]any{
+		"Name": "Dave",
+		"URL":  "https://localhost:4000/user/verify?token=abc",
+		"Days": 3,
+	}
+
+	msg, err := NewMessage("dave@example.com", "verification.tmpl", data)
+	assert.NilError(t, err)
+
+	assert.Equal(t, msg.Subject, "Confirm your Snippetbox email address")
+	assert.StringContains(t, msg.Body, "https://localhost:4000/user/verify?token=abc")
+	assert.StringContains(t, msg.Body, "expires in 3 days")
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"log"
+	"sync"
+)
+
+// A LogMailer writes emails to a logger instead of sending them. It's used
+// in development with -mailer=log, so you can follow the links in password
+// reset emails without setting up an SMTP server.
+type LogMailer struct {
+	Logger *log.Logger
+}
+
+func (m *LogMailer) Send(msg *Message) error {
+	m.Logger.Printf("Email to %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// A MemoryMailer keeps the emails it's asked to send, so that tests can
+// check what would have been sent. It's safe for concurrent use.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []*Message
+}
+
+func (m *MemoryMailer) Send(msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// The Messages() method returns the emails sent so far, oldest first.
+func (m *MemoryMailer) Messages() []*Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*Message{}, m.messages...)
+}
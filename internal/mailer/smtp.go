@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An SMTPMailer sends emails through an SMTP server. The Username and
+// Password are optional, and if they're empty we don't authenticate at all,
+// which is what local test servers like MailHog expect. Sender is the From
+// address, like "Snippetbox <no-reply@snippetbox.example.org>".
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+}
+
+// The Send() method sends a plain-text email. The net/smtp package upgrades
+// the connection with STARTTLS whenever the server supports it.
+func (m *SMTPMailer) Send(msg *Message) error {
+	from, err := mail.ParseAddress(m.Sender)
+	if err != nil {
+		return fmt.Errorf("mailer: invalid sender: %w", err)
+	}
+
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("mailer: invalid recipient: %w", err)
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+
+	return smtp.SendMail(addr, auth, from.Address, []string{to.Address}, format(from, to, msg, time.Now()))
+}
+
+// The format() function builds the RFC 5322 message which is sent to the
+// server. The addresses have already been parsed, and the subject is
+// encoded, so nothing in the message can add extra headers.
+func format(from, to *mail.Address, msg *Message, date time.Time) []byte {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+
+	// SMTP needs CRLF line endings in the body as well as the headers.
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return b.Bytes()
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type CommentModelInterface interface {
+	Insert(snippetID, userID, parentID, line int, body string) (int, error)
+	Get(id int) (*Comment, error)
+	ForSnippet(snippetID int) ([]*Comment, error)
+	Delete(id int) error
+}
+
+// Define a Comment type to hold a comment on a snippet. Comments are threaded
+// one level deep: a top-level comment has a ParentID of 0, and its replies
+// have the top-level comment's ID as their ParentID and are held in its
+// Replies field. Line is the line of the snippet that a top-level comment is
+// about, or 0 if it's about the snippet as a whole.
+type Comment struct {
+	ID        int
+	SnippetID int
+	UserID    int
+	UserName  string
+	ParentID  int
+	Line      int
+	Body      string
+	Created   time.Time
+	Replies   []*Comment
+}
+
+// Define a CommentModel type which wraps a database connection pool.
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// The commentColumns constant holds the column list for queries which return
+// comments. The queries alias the comments table as "c" and join the users
+// table as "u", and the columns must stay in the same order as the arguments
+// to Scan() in scanComment().
+const commentColumns = `c.id, c.snippet_id, c.user_id, u.name, c.parent_id, c.line, c.body, c.created`
+
+// The scanComment() function copies the columns listed in commentColumns into
+// a new Comment struct. The parent_id and line columns are NULL when they
+// aren't set, which we turn into 0.
+func scanComment(row scanner) (*Comment, error) {
+	c := &Comment{}
+
+	var parentID, line sql.NullInt64
+
+	err := row.Scan(&c.ID, &c.SnippetID, &c.UserID, &c.UserName, &parentID, &line, &c.Body, &c.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	c.ParentID = int(parentID.Int64)
+	c.Line = int(line.Int64)
+
+	return c, nil
+}
+
+// This will add a new comment to a snippet and return its ID. Pass a parentID
+// of 0 for a top-level comment, and a line of 0 if the comment isn't about a
+// particular line. The caller is responsible for checking that the parent
+// is a top-level comment on the same snippet.
+func (m *CommentModel) Insert(snippetID, userID, parentID, line int, body string) (int, error) {
+	stmt := `INSERT INTO comments (snippet_id, user_id, parent_id, line, body, created)
+	VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	var parent, lineNumber sql.NullInt64
+	if parentID != 0 {
+		parent = sql.NullInt64{Int64: int64(parentID), Valid: true}
+	}
+	if line != 0 {
+		lineNumber = sql.NullInt64{Int64: int64(line), Valid: true}
+	}
+
+	result, err := m.DB.Exec(stmt, snippetID, userID, parent, lineNumber, body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// This will return a specific comment, or ErrNoRecord if it doesn't exist.
+func (m *CommentModel) Get(id int) (*Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	FROM comments c INNER JOIN users u ON u.id = c.user_id
+	WHERE c.id = ?`
+
+	c, err := scanComment(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// This will return the comments on a snippet as a list of threads, oldest
+// first. Each top-level comment holds its replies, which are also oldest
+// first.
+func (m *CommentModel) ForSnippet(snippetID int) ([]*Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	FROM comments c INNER JOIN users u ON u.id = c.user_id
+	WHERE c.snippet_id = ? ORDER BY c.id`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Because the comments are ordered by ID, a reply always comes after the
+	// comment it's replying to, so we can build the threads in one pass.
+	threads := []*Comment{}
+	byID := make(map[int]*Comment)
+
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent, ok := byID[c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+			continue
+		}
+
+		threads = append(threads, c)
+		byID[c.ID] = c
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return threads, nil
+}
+
+// This will delete a comment. Deleting a top-level comment deletes all of
+// its replies too, because they'd make no sense on their own.
+func (m *CommentModel) Delete(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM comments WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestCommentModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := CommentModel{db}
+
+	rootID, err := m.Insert(1, 1, 0, 1, "Where is the frog?")
+	assert.NilError(t, err)
+
+	replyID, err := m.Insert(1, 1, rootID, 0, "It jumps in later.")
+	assert.NilError(t, err)
+
+	_, err = m.Insert(1, 1, 0, 0, "Lovely.")
+	assert.NilError(t, err)
+
+	threads, err := m.ForSnippet(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(threads), 2)
+	assert.Equal(t, threads[0].Line, 1)
+	assert.Equal(t, threads[0].UserName, "Alice Jones")
+	assert.Equal(t, len(threads[0].Replies), 1)
+	assert.Equal(t, threads[0].Replies[0].ID, replyID)
+	assert.Equal(t, threads[1].Line, 0)
+
+	// Deleting the first comment in a thread deletes the replies too.
+	err = m.Delete(rootID)
+	assert.NilError(t, err)
+
+	_, err = m.Get(replyID)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	threads, err = m.ForSnippet(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(threads), 1)
+}
@@ -0,0 +1,70 @@
+package models
+
+import "database/sql"
+
+// Define a File type to hold one of the extra files attached to a snippet. The
+// snippet's own Content and Language fields hold its main file, and any
+// further files (like the go.mod which goes with a main.go) are stored in the
+// snippet_files table. Names are unique within a snippet.
+type File struct {
+	Name     string
+	Language string
+	Content  string
+}
+
+// MaxFiles is the maximum number of extra files a snippet can have.
+const MaxFiles = 10
+
+// The querier interface is satisfied by both *sql.DB and *sql.Tx, so that
+// the file helpers can be used inside or outside of a transaction.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// The insertFiles() function stores the extra files for a snippet, keeping
+// them in the order they were given.
+func insertFiles(q querier, snippetID int, files []*File) error {
+	stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, content)
+	VALUES(?, ?, ?, ?, ?)`
+
+	for i, f := range files {
+		_, err := q.Exec(stmt, snippetID, i+1, f.Name, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The loadFiles() function returns the extra files for a snippet, in order.
+func loadFiles(q querier, snippetID int) ([]*File, error) {
+	stmt := `SELECT name, language, content FROM snippet_files
+	WHERE snippet_id = ? ORDER BY position`
+
+	rows, err := q.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []*File{}
+
+	for rows.Next() {
+		f := &File{}
+
+		err = rows.Scan(&f.Name, &f.Language, &f.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
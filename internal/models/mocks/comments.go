@@ -0,0 +1,58 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.example.org/internal/models"
+)
+
+// A comment by Bob on the first line of the first snippet, with a reply from
+// Alice.
+var mockComment = &models.Comment{
+	ID:        1,
+	SnippetID: 1,
+	UserID:    2,
+	UserName:  "Bob Smith",
+	Line:      1,
+	Body:      "Where is the frog?",
+	Created:   time.Now(),
+	Replies:   []*models.Comment{mockReply},
+}
+
+var mockReply = &models.Comment{
+	ID:        2,
+	SnippetID: 1,
+	UserID:    1,
+	UserName:  "Alice Jones",
+	ParentID:  1,
+	Body:      "It jumps in later.",
+	Created:   time.Now(),
+}
+
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(snippetID, userID, parentID, line int, body string) (int, error) {
+	return 3, nil
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	switch id {
+	case 1:
+		return mockComment, nil
+	case 2:
+		return mockReply, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	if snippetID == 1 {
+		return []*models.Comment{mockComment}, nil
+	}
+	return []*models.Comment{}, nil
+}
+
+func (m *CommentModel) Delete(id int) error {
+	return nil
+}
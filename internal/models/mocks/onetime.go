@@ -0,0 +1,28 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.example.org/internal/models"
+)
+
+type OneTimeTokenModel struct{}
+
+func (m *OneTimeTokenModel) New(userID int, scope string, ttl time.Duration) (string, error) {
+	return "ONETIMETOKENONETIMETOKENONETIMET", nil
+}
+
+func (m *OneTimeTokenModel) Consume(plaintext, scope string) (int, error) {
+	if plaintext == "valid-token" {
+		return 1, nil
+	}
+	return 0, models.ErrInvalidCredentials
+}
+
+func (m *OneTimeTokenModel) DeleteAllForUser(userID int, scope string) error {
+	return nil
+}
+
+func (m *OneTimeTokenModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	return 0, nil
+}
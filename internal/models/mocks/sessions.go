@@ -0,0 +1,9 @@
+package mocks
+
+import "time"
+
+type SessionModel struct{}
+
+func (m *SessionModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	return 0, nil
+}
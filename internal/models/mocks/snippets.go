@@ -1,34 +1,244 @@
 package mocks
 
 import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"snippetbox.example.org/internal/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     1,
+	UserName:   "Alice Jones",
+	Forks:      1,
+	Tags:       []string{"billing", "infra"},
 }
 
-type SnippetModel struct{}
+// A second snippet which belongs to a different user, so that we can test
+// the ownership checks on the edit and delete routes. It has an extra file,
+// and was forked from the first snippet.
+var mockOtherSnippet = &models.Snippet{
+	ID:         3,
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest, winds howl in rage...",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	UserID:     2,
+	UserName:   "Bob Smith",
+	Files: []*models.File{
+		{Name: "notes.txt", Language: "plaintext", Content: "with no leaves to blow."},
+	},
+	ForkedFrom: 1,
+	Tags:       []string{"infra"},
+	Stars:      1,
+}
+
+// A burn-after-reading snippet, which the real model would delete as soon as
+// it has been viewed.
+var mockBurnSnippet = &models.Snippet{
+	ID:               4,
+	Title:            "Contractor credentials",
+	Content:          "hunter2",
+	Language:         "plaintext",
+	Visibility:       models.VisibilityUnlisted,
+	Created:          time.Now(),
+	Updated:          time.Now(),
+	Expires:          time.Now(),
+	BurnAfterReading: true,
+	UserID:           1,
+	UserName:         "Alice Jones",
+}
+
+// An unlisted snippet, which can be viewed by anyone with the link but is
+// never listed.
+var mockUnlistedSnippet = &models.Snippet{
+	ID:         5,
+	Title:      "First autumn morning",
+	Content:    "First autumn morning, the mirror I stare into...",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	UserID:     1,
+	UserName:   "Alice Jones",
+}
+
+// A private snippet, which only its owner (Alice) can see.
+var mockPrivateSnippet = &models.Snippet{
+	ID:         6,
+	Title:      "Shopping list",
+	Content:    "Rice, tea, plum blossom",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	UserID:     1,
+	UserName:   "Alice Jones",
+}
+
+// A password-protected snippet belonging to Bob. The password is "letmein".
+var mockProtectedSnippet = &models.Snippet{
+	ID:         7,
+	Title:      "Wi-Fi details",
+	Content:    "The network is called basho",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	Protected:  true,
+	Created:    time.Now(),
+	Updated:    time.Now(),
+	UserID:     2,
+	UserName:   "Bob Smith",
+}
+
+var mockRevision = &models.Revision{
+	ID:        1,
+	SnippetID: 1,
+	Title:     "An old silent pond",
+	Content:   "An old pond...",
+	Created:   time.Now(),
+}
+
+// The SnippetModel mock remembers the last snippet that was inserted, with
+// the ID 2, so that handlers which read a new snippet back can find it.
+type SnippetModel struct {
+	mu       sync.Mutex
+	inserted *models.Snippet
+}
+
+func (m *SnippetModel) Insert(snippet *models.Snippet) (int, error) {
+	inserted := *snippet
+	inserted.ID = 2
+	inserted.Created = time.Now()
+	inserted.Updated = inserted.Created
+	inserted.Protected = snippet.Password != ""
+	inserted.Password = ""
+
+	m.mu.Lock()
+	m.inserted = &inserted
+	m.mu.Unlock()
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
 	return 2, nil
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	switch id {
+// mockSnippets holds every mock snippet, in ID order.
+var mockSnippets = []*models.Snippet{mockSnippet, mockOtherSnippet, mockBurnSnippet, mockUnlistedSnippet, mockPrivateSnippet, mockProtectedSnippet}
+
+func (m *SnippetModel) Get(id int, viewerID int) (*models.Snippet, error) {
+	m.mu.Lock()
+	inserted := m.inserted
+	m.mu.Unlock()
+
+	snippets := mockSnippets
+	if inserted != nil {
+		snippets = append(snippets, inserted)
+	}
+
+	for _, s := range snippets {
+		if s.ID != id {
+			continue
+		}
+		if s.Visibility == models.VisibilityPrivate && s.UserID != viewerID {
+			return nil, models.ErrNoRecord
+		}
+		return s, nil
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) View(id int, viewerID int) (*models.Snippet, error) {
+	return m.Get(id, viewerID)
+}
+
+// List() sorts and pages the mock snippets in memory, so that handler tests
+// can check the behaviour on the first, last and out-of-range pages.
+func (m *SnippetModel) List(filters models.Filters) ([]*models.Snippet, models.Metadata, error) {
+	snippets := []*models.Snippet{}
+	for _, s := range mockSnippets {
+		if filters.UserID != 0 && s.UserID != filters.UserID {
+			continue
+		}
+		if filters.Tag != "" && !slices.Contains(s.Tags, filters.Tag) {
+			continue
+		}
+		if s.Visibility == models.VisibilityPublic && !s.BurnAfterReading {
+			snippets = append(snippets, s)
+		}
+	}
+
+	sort.SliceStable(snippets, func(i, j int) bool {
+		a, b := snippets[i], snippets[j]
+		if strings.HasPrefix(filters.Sort, "-") {
+			a, b = b, a
+		}
+		if strings.TrimPrefix(filters.Sort, "-") == "title" {
+			return a.Title < b.Title
+		}
+		return a.ID < b.ID
+	})
+
+	metadata := models.CalculateMetadata(len(snippets), filters.Page, filters.PageSize)
+
+	start := min(len(snippets), (filters.Page-1)*filters.PageSize)
+	end := min(len(snippets), start+filters.PageSize)
+
+	return snippets[start:end], metadata, nil
+}
+
+func (m *SnippetModel) Update(id int, title string, content string, language string, visibility string) error {
+	return nil
+}
+
+func (m *SnippetModel) Unlock(id int, password string) error {
+	if id != mockProtectedSnippet.ID {
+		return models.ErrNoRecord
+	}
+	if password != "letmein" {
+		return models.ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	return nil
+}
+
+func (m *SnippetModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	switch snippetID {
 	case 1:
-		return mockSnippet, nil
+		return []*models.Revision{mockRevision}, nil
 	default:
-		return nil, models.ErrNoRecord
+		return []*models.Revision{}, nil
 	}
 }
 
-func (m *SnippetModel) Lastest() ([]*models.Snippet, error) {
-	return []*models.Snippet{mockSnippet}, nil
+func (m *SnippetModel) Revision(snippetID int, id int) (*models.Revision, error) {
+	if snippetID == 1 && id == 1 {
+		return mockRevision, nil
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *SnippetModel) Search(query string, page int) ([]*models.Snippet, models.Metadata, error) {
+	if strings.Contains(strings.ToLower(query), "pond") && page == 1 {
+		metadata := models.Metadata{CurrentPage: 1, PageSize: 10, FirstPage: 1, LastPage: 1, TotalRecords: 1}
+		return []*models.Snippet{mockSnippet}, metadata, nil
+	}
+	return []*models.Snippet{}, models.Metadata{}, nil
 }
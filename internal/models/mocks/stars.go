@@ -0,0 +1,26 @@
+package mocks
+
+import "snippetbox.example.org/internal/models"
+
+// The StarModel mock reports that Alice (user 1) has starred Bob's snippet
+// (ID 3), and nothing else.
+type StarModel struct{}
+
+func (m *StarModel) Add(userID, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Remove(userID, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Exists(userID, snippetID int) (bool, error) {
+	return userID == 1 && snippetID == 3, nil
+}
+
+func (m *StarModel) ForUser(userID int) ([]*models.Snippet, error) {
+	if userID == 1 {
+		return []*models.Snippet{mockOtherSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
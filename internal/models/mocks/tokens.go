@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.example.org/internal/models"
+)
+
+var mockToken = &models.Token{
+	ID:      1,
+	UserID:  1,
+	Name:    "CI server",
+	Created: time.Now(),
+}
+
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(userID int, name string, expires time.Time) (string, error) {
+	return "NEWTOKENNEWTOKENNEWTOKENNEWTOKEN", nil
+}
+
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	if plaintext == "valid-token" {
+		return 1, nil
+	}
+	return 0, models.ErrInvalidCredentials
+}
+
+func (m *TokenModel) ForUser(userID int) ([]*models.Token, error) {
+	if userID == 1 {
+		return []*models.Token{mockToken}, nil
+	}
+	return []*models.Token{}, nil
+}
+
+func (m *TokenModel) Delete(userID int, id int) error {
+	if userID == 1 && id == 1 {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *TokenModel) DeleteAllForUser(userID int) error {
+	return nil
+}
+
+func (m *TokenModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	return 0, nil
+}
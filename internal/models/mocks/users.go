@@ -1,30 +1,106 @@
 package mocks
 
-import "snippetbox.example.org/internal/models"
+import (
+	"time"
 
+	"snippetbox.example.org/internal/models"
+)
+
+// The UserModel mock has three users. Alice and Bob have confirmed their
+// email addresses, but Carol (who can log in with the same password as
+// Alice) hasn't yet.
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(name, email, password string) (int, error) {
 	switch email {
 	case "dupe@example.com":
-		return models.ErrDuplicateEmail
+		return 0, models.ErrDuplicateEmail
 	default:
-		return nil
+		return 4, nil
 	}
 }
 
 func (m *UserModel) Authenticate(email, password string) (int, error) {
-	if email == "alice@example.com" && password == "pa$$word" {
+	if password != "pa$$word" {
+		return 0, models.ErrInvalidCredentials
+	}
+
+	switch email {
+	case "alice@example.com":
 		return 1, nil
+	case "carol@example.com":
+		return 3, nil
+	default:
+		return 0, models.ErrInvalidCredentials
 	}
-	return 0, models.ErrInvalidCredentials
 }
 
 func (m *UserModel) Exists(id int) (bool, error) {
 	switch id {
-	case 1:
+	case 1, 3:
 		return true, nil
 	default:
 		return false, nil
 	}
 }
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	switch id {
+	case 1:
+		return &models.User{
+			ID:       1,
+			Name:     "Alice Jones",
+			Email:    "alice@example.com",
+			Created:  time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC),
+			Verified: true,
+		}, nil
+	case 2:
+		return &models.User{
+			ID:       2,
+			Name:     "Bob Smith",
+			Email:    "bob@example.com",
+			Created:  time.Date(2022, 3, 15, 9, 30, 0, 0, time.UTC),
+			Verified: true,
+		}, nil
+	case 3:
+		return &models.User{
+			ID:      3,
+			Name:    "Carol White",
+			Email:   "carol@example.com",
+			Created: time.Date(2022, 6, 1, 8, 0, 0, 0, time.UTC),
+		}, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+	if id == 1 {
+		if currentPassword != "pa$$word" {
+			return models.ErrInvalidCredentials
+		}
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) GetByEmail(email string) (*models.User, error) {
+	switch email {
+	case "alice@example.com":
+		return m.Get(1)
+	case "bob@example.com":
+		return m.Get(2)
+	case "carol@example.com":
+		return m.Get(3)
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *UserModel) PasswordSet(id int, newPassword string) error {
+	return nil
+}
+
+func (m *UserModel) Verify(id int) error {
+	return nil
+}
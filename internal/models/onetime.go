@@ -0,0 +1,117 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Define constants for the scopes of one-time tokens. The scope is checked
+// when a token is used, so that a token sent for one purpose can't be used
+// for another.
+const (
+	ScopePasswordReset = "password-reset"
+	ScopeVerification  = "verification"
+)
+
+type OneTimeTokenModelInterface interface {
+	New(userID int, scope string, ttl time.Duration) (string, error)
+	Consume(plaintext, scope string) (int, error)
+	DeleteAllForUser(userID int, scope string) error
+	DeleteExpired(before time.Time, limit int) (int, error)
+}
+
+// Define a OneTimeTokenModel type which wraps a database connection pool.
+// One-time tokens are short-lived, single-use tokens which we email to users,
+// for example in a password reset link. Like API tokens, we only store a
+// SHA-256 hash of them.
+type OneTimeTokenModel struct {
+	DB *sql.DB
+}
+
+// This will create a new token for the user with the given scope, which
+// expires after ttl, and return it in plain text.
+func (m *OneTimeTokenModel) New(userID int, scope string, ttl time.Duration) (string, error) {
+	plaintext, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO one_time_tokens (hash, user_id, scope, expires)
+	VALUES(?, ?, ?, ?)`
+
+	_, err = m.DB.Exec(stmt, hashToken(plaintext), userID, scope, time.Now().Add(ttl).UTC())
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// We'll use the Consume method to use up a token, returning the ID of the
+// user it belongs to. The token is deleted in the same transaction, so it
+// can only ever be used once, even by two requests at the same time. If the
+// token doesn't exist, has expired or has a different scope we return the
+// ErrInvalidCredentials error.
+func (m *OneTimeTokenModel) Consume(plaintext, scope string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+
+	stmt := `SELECT user_id FROM one_time_tokens
+	WHERE hash = ? AND scope = ? AND expires > UTC_TIMESTAMP() FOR UPDATE`
+
+	err = tx.QueryRow(stmt, hashToken(plaintext), scope).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM one_time_tokens WHERE hash = ?`, hashToken(plaintext))
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// This will delete all of a user's tokens with the given scope. We use it
+// once a token has done its job, so that any other links we've sent the
+// user stop working too.
+func (m *OneTimeTokenModel) DeleteAllForUser(userID int, scope string) error {
+	stmt := `DELETE FROM one_time_tokens WHERE user_id = ? AND scope = ?`
+
+	_, err := m.DB.Exec(stmt, userID, scope)
+	return err
+}
+
+// This will delete up to limit tokens which expired before the given time,
+// returning the number of rows removed. Tokens which have been used are
+// deleted by Consume(), so these are the links that were never followed.
+func (m *OneTimeTokenModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	stmt := `DELETE FROM one_time_tokens WHERE expires <= ? LIMIT ?`
+
+	result, err := m.DB.Exec(stmt, before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestOneTimeTokenModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := OneTimeTokenModel{db}
+
+	token, err := m.New(1, ScopePasswordReset, time.Hour)
+	assert.NilError(t, err)
+
+	// A token can't be used for a different scope.
+	_, err = m.Consume(token, "other-scope")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	userID, err := m.Consume(token, ScopePasswordReset)
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	// Each token can only be used once.
+	_, err = m.Consume(token, ScopePasswordReset)
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	// Expired tokens don't work.
+	expired, err := m.New(1, ScopePasswordReset, -time.Minute)
+	assert.NilError(t, err)
+
+	_, err = m.Consume(expired, ScopePasswordReset)
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	// Expired tokens are purged. The used token has already gone.
+	n, err := m.DeleteExpired(time.Now(), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	// Deleting a user's tokens stops the rest of them working.
+	other, err := m.New(1, ScopePasswordReset, time.Hour)
+	assert.NilError(t, err)
+
+	err = m.DeleteAllForUser(1, ScopePasswordReset)
+	assert.NilError(t, err)
+
+	_, err = m.Consume(other, ScopePasswordReset)
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+}
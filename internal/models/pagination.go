@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"snippetbox.example.org/internal/validator"
+)
+
+// The SortSafelist holds the values which are accepted for the Sort field of
+// Filters. A leading "-" means descending order. Anything not in this list
+// must be rejected before it gets anywhere near the SQL.
+var SortSafelist = []string{"created", "expires", "title", "-created", "-expires", "-title"}
+
+// The maxPage constant is the highest page number we'll fetch. Anything
+// bigger would make the OFFSET overflow.
+const maxPage = 10_000
+
+// Define a Filters type to hold the paging and sorting options for listing
+// snippets. If UserID is set, only that user's snippets are listed, and if
+// Tag is set, only the snippets with that tag.
+type Filters struct {
+	Page     int
+	PageSize int
+	Sort     string
+	UserID   int
+	Tag      string
+}
+
+// ValidateFilters() checks the paging and sorting options, adding an error to
+// the validator for each one that is out of range.
+func ValidateFilters(v *validator.Validator, f Filters) {
+	v.CheckField(f.Page > 0, "page", "must be greater than zero")
+	v.CheckField(f.Page <= maxPage, "page", "must be a maximum of 10 thousand")
+	v.CheckField(f.PageSize > 0, "page_size", "must be greater than zero")
+	v.CheckField(f.PageSize <= 100, "page_size", "must be a maximum of 100")
+	v.CheckField(validator.PermittedValue(f.Sort, SortSafelist...), "sort", "invalid sort value")
+}
+
+// The sortColumn() method returns the column name to sort by. As a sanity
+// check it panics if the sort value isn't in the safelist, which would mean
+// the value hadn't been validated by the caller.
+func (f Filters) sortColumn() string {
+	for _, safeValue := range SortSafelist {
+		if f.Sort == safeValue {
+			return strings.TrimPrefix(f.Sort, "-")
+		}
+	}
+
+	panic("unsafe sort parameter: " + f.Sort)
+}
+
+// The sortDirection() method returns the SQL sort direction.
+func (f Filters) sortDirection() string {
+	if strings.HasPrefix(f.Sort, "-") {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// The orderBy() method returns the ORDER BY clause for a query on the
+// snippets table aliased as "s". Snippets which never expire have a NULL
+// expiry time, so when sorting by expiry we sort on whether it's NULL first,
+// to put them after all the snippets which do expire. We also sort on the id
+// as a tie-breaker, so that the order is stable between pages.
+func (f Filters) orderBy() string {
+	column, direction := f.sortColumn(), f.sortDirection()
+
+	if column == "expires" {
+		return fmt.Sprintf("s.expires IS NULL %s, s.expires %s, s.id %s", direction, direction, direction)
+	}
+
+	return fmt.Sprintf("s.%s %s, s.id %s", column, direction, direction)
+}
+
+func (f Filters) limit() int {
+	return f.PageSize
+}
+
+func (f Filters) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// Define a Metadata type to hold the pagination details for a page of
+// results, so that templates can show "page X of Y" and link to the previous
+// and next pages.
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty"`
+}
+
+// The CalculateMetadata() function works out the pagination metadata from the
+// total number of matching records, the current page and the page size. If
+// there are no records we return an empty Metadata struct.
+func CalculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	return Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     (totalRecords + pageSize - 1) / pageSize,
+		TotalRecords: totalRecords,
+	}
+}
+
+// HasPrevious() returns true if there is a page before the current one.
+func (m Metadata) HasPrevious() bool {
+	return m.CurrentPage > m.FirstPage
+}
+
+// HasNext() returns true if there is a page after the current one.
+func (m Metadata) HasNext() bool {
+	return m.CurrentPage < m.LastPage
+}
+
+// PreviousPage() returns the number of the page before the current one.
+func (m Metadata) PreviousPage() int {
+	return m.CurrentPage - 1
+}
+
+// NextPage() returns the number of the page after the current one.
+func (m Metadata) NextPage() int {
+	return m.CurrentPage + 1
+}
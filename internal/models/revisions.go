@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Define a Revision type to hold an earlier version of a snippet. A new
+// revision is recorded each time a snippet is edited, and the Created field
+// holds the time at which that version was replaced.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// This will return all the earlier versions of a snippet, oldest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*Revision, error) {
+	stmt := `SELECT id, snippet_id, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? ORDER BY id ASC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+
+	for rows.Next() {
+		rv := &Revision{}
+
+		err = rows.Scan(&rv.ID, &rv.SnippetID, &rv.Title, &rv.Content, &rv.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, rv)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// This will return a specific revision of a snippet. We check the snippet ID
+// as well as the revision ID, so that a revision can't be looked up through a
+// different snippet's URL.
+func (m *SnippetModel) Revision(snippetID int, id int) (*Revision, error) {
+	stmt := `SELECT id, snippet_id, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? AND id = ?`
+
+	rv := &Revision{}
+
+	err := m.DB.QueryRow(stmt, snippetID, id).Scan(&rv.ID, &rv.SnippetID, &rv.Title, &rv.Content, &rv.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return rv, nil
+}
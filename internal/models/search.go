@@ -0,0 +1,64 @@
+package models
+
+// The number of results shown on each page of search results.
+const searchPageSize = 10
+
+// This will return a page of snippets whose title or content match the search
+// query, best matches first, along with the pagination metadata. It relies on
+// the FULLTEXT index on the title and content columns of the snippets table,
+// and uses MySQL's natural language mode so that the query is treated as a
+// plain list of words rather than boolean search syntax. Only public
+// snippets without a password are searched, otherwise the content of
+// unlisted, private, protected and burn-after-reading snippets would leak
+// into the results.
+func (m *SnippetModel) Search(query string, page int) ([]*Snippet, Metadata, error) {
+	// Clamp the page number, so that a huge value can't make the OFFSET
+	// overflow.
+	page = min(max(page, 1), maxPage)
+
+	// First count the total number of matches, so that we can tell the user
+	// how many pages of results there are.
+	stmt := `SELECT COUNT(*) FROM snippets s
+	WHERE ` + notExpired + ` AND ` + listed + ` AND ` + unprotected + `
+	AND MATCH(s.title, s.content) AGAINST (? IN NATURAL LANGUAGE MODE)`
+
+	var totalRecords int
+
+	err := m.DB.QueryRow(stmt, query).Scan(&totalRecords)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// Then fetch the current page. MySQL returns the relevance score from
+	// MATCH() ... AGAINST(), which we sort by so that the best matches come
+	// first.
+	stmt = `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND ` + listed + ` AND ` + unprotected + `
+	AND MATCH(s.title, s.content) AGAINST (? IN NATURAL LANGUAGE MODE)
+	ORDER BY MATCH(s.title, s.content) AGAINST (? IN NATURAL LANGUAGE MODE) DESC, s.id DESC
+	LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, query, query, searchPageSize, (page-1)*searchPageSize)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return snippets, CalculateMetadata(totalRecords, page, searchPageSize), nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type SessionModelInterface interface {
+	DeleteExpired(before time.Time, limit int) (int, error)
+}
+
+// The sessions table is owned by the scs/mysqlstore package, which reads and
+// writes the session data itself. The SessionModel type only exists so that
+// we can clear out expired sessions in batches alongside expired snippets,
+// rather than leaving it to the store's own cleanup goroutine.
+type SessionModel struct {
+	DB *sql.DB
+}
+
+// This will delete up to limit sessions which expired before the given time,
+// returning the number of rows removed.
+func (m *SessionModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	stmt := `DELETE FROM sessions WHERE expiry < ? LIMIT ?`
+
+	result, err := m.DB.Exec(stmt, before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
@@ -3,44 +3,204 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
-	Lastest() ([]*Snippet, error)
+	Insert(snippet *Snippet) (int, error)
+	Get(id int, viewerID int) (*Snippet, error)
+	View(id int, viewerID int) (*Snippet, error)
+	List(filters Filters) ([]*Snippet, Metadata, error)
+	Update(id int, title string, content string, language string, visibility string) error
+	Unlock(id int, password string) error
+	Delete(id int) error
+	DeleteExpired(before time.Time, limit int) (int, error)
+	Revisions(snippetID int) ([]*Revision, error)
+	Revision(snippetID int, id int) (*Revision, error)
+	Search(query string, page int) ([]*Snippet, Metadata, error)
 }
 
 // Define a Snippet type to hold the data for an individual snippet. Notice how
 // the fields of the struct correspond to the fields in our MySQL snippets
-// table?
+// table? The UserName field isn't stored on the snippets table itself, it's
+// joined in from the users table so that we can show who wrote the snippet.
+// The Language field holds one of the values from highlight.Languages. If
+// BurnAfterReading is true the snippet is deleted the first time it's viewed.
+// Updated is the time the snippet was last edited, which is the same as
+// Created for a snippet that has never been edited. A zero Expires time
+// means that the snippet never expires. Visibility holds
+// one of the Visibility constants below. Password is the optional plain-text
+// password for a new snippet, which Insert() hashes before storing. It's
+// never read back from the database, so use Protected to find out whether a
+// snippet has a password. Files holds any extra files, and is only filled in
+// by Get() and View(). ForkedFrom is the ID of the snippet this one was
+// forked from, or 0 if it wasn't forked (or the original has since been
+// deleted), and Forks is the number of current snippets forked from this one.
+// Tags holds the snippet's tags in alphabetical order, and Stars is the
+// number of users who have starred the snippet.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID               int
+	Title            string
+	Content          string
+	Language         string
+	Visibility       string
+	Created          time.Time
+	Updated          time.Time
+	Expires          time.Time
+	BurnAfterReading bool
+	Password         string
+	Protected        bool
+	UserID           int
+	UserName         string
+	Files            []*File
+	ForkedFrom       int
+	Forks            int
+	Tags             []string
+	Stars            int
 }
 
+// The visibility of a snippet controls who can see it. Public snippets are
+// listed on the home page and in search results. Unlisted snippets can be
+// viewed by anyone who has the link, but are never listed. Private snippets
+// can only be viewed by the user who created them.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Visibilities holds the permitted visibility values, in the order that they
+// should be offered to users.
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
 // Define a SnippetModel type which wraps a sql.DB connection pool.
 type SnippetModel struct {
 	DB *sql.DB
 }
 
-// This will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// The snippetColumns constant holds the column list which is used by every
+// query that returns whole snippets. The queries alias the snippets table as
+// "s" and join the users table as "u", and the columns must stay in the same
+// order as the arguments to Scan() in scanSnippet(). The last three columns
+// count the forks of each snippet which haven't expired, join its tags into a
+// comma-separated list, and count its stars.
+const snippetColumns = `s.id, s.title, s.content, s.language, s.visibility, s.created, s.updated, s.expires, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, u.name, s.forked_from,
+	(SELECT COUNT(*) FROM snippets f WHERE f.forked_from = s.id AND (f.expires IS NULL OR f.expires > UTC_TIMESTAMP())),
+	(SELECT GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR ',') FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id WHERE st.snippet_id = s.id),
+	(SELECT COUNT(*) FROM stars sr WHERE sr.snippet_id = s.id)`
+
+// The notExpired constant holds the WHERE condition which hides expired
+// snippets. Snippets which never expire have a NULL expires column.
+const notExpired = `(s.expires IS NULL OR s.expires > UTC_TIMESTAMP())`
+
+// The visibleTo constant holds the WHERE condition which hides private
+// snippets from everyone except their owner. It has a placeholder for the ID
+// of the user who is viewing the snippet, which is 0 for anonymous visitors.
+const visibleTo = `(s.visibility <> 'private' OR s.user_id = ?)`
+
+// The listed constant holds the WHERE condition for snippets which can appear
+// in listings and search results. Only public snippets are listed, and
+// burn-after-reading snippets never are, because they're meant for one
+// person only.
+const listed = `s.visibility = 'public' AND NOT s.burn_after_reading`
+
+// The unprotected constant holds the WHERE condition for snippets which don't
+// have a password. It's used where we'd otherwise show snippet content to
+// someone who hasn't unlocked it, such as in search results.
+const unprotected = `s.hashed_password IS NULL`
+
+// The scanner interface is satisfied by both *sql.Row and *sql.Rows, so that
+// scanSnippet() can be used with QueryRow() and Query() alike.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// The scanSnippet() function copies the columns listed in snippetColumns into
+// a new Snippet struct.
+func scanSnippet(row scanner) (*Snippet, error) {
+	s := &Snippet{}
+
+	// The expires column can be NULL, so we scan it into a sql.NullTime and
+	// leave s.Expires as the zero time if there's no value. The same goes for
+	// forked_from, and the list of tags is NULL if there aren't any.
+	var expires sql.NullTime
+	var forkedFrom sql.NullInt64
+	var tags sql.NullString
+
+	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Language, &s.Visibility, &s.Created, &s.Updated, &expires, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.UserName, &forkedFrom, &s.Forks, &tags, &s.Stars)
+	if err != nil {
+		return nil, err
+	}
+
+	if expires.Valid {
+		s.Expires = expires.Time
+	}
+
+	if forkedFrom.Valid {
+		s.ForkedFrom = int(forkedFrom.Int64)
+	}
+
+	s.Tags = []string{}
+	if tags.Valid {
+		s.Tags = strings.Split(tags.String, ",")
+	}
+
+	return s, nil
+}
+
+// This will insert a new snippet into the database. The Title, Content,
+// Language, Visibility, Expires, BurnAfterReading, Password, UserID, Files,
+// ForkedFrom and Tags fields of the snippet are stored, and the others are
+// ignored. It returns the ID of the new snippet.
+func (m *SnippetModel) Insert(snippet *Snippet) (int, error) {
 	// Write the SQL statement we want to execute. I've split it over two lines
 	// for readability (which is why it's surrounded with backquotes instead
 	// of normal double quotes).
-	stmt := `INSERT INTO snippets (title, content, created, expires) VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (title, content, language, visibility, created, updated, expires, burn_after_reading, hashed_password, user_id, forked_from)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?, ?, ?, ?, ?)`
 
-	// Use the Exec() method on the embedded connection pool to execute the
-	// statement. The first parameter is the SQL statement, followed by the
-	// title, content and expiry values for the placeholder parameters. This
-	// method returns a sql.Result type, which contains some basic
-	// information about what happened when the statement was executed.
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	// A snippet which never expires is stored with a NULL expiry time.
+	var expires sql.NullTime
+	if !snippet.Expires.IsZero() {
+		expires = sql.NullTime{Time: snippet.Expires.UTC(), Valid: true}
+	}
+
+	// A snippet which wasn't forked is stored with a NULL forked_from.
+	var forkedFrom sql.NullInt64
+	if snippet.ForkedFrom != 0 {
+		forkedFrom = sql.NullInt64{Int64: int64(snippet.ForkedFrom), Valid: true}
+	}
+
+	// If the snippet has a password, create a bcrypt hash of it in exactly
+	// the same way as we do for user accounts. Snippets without a password
+	// are stored with a NULL hashed_password.
+	var hashedPassword sql.NullString
+	if snippet.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(snippet.Password), 12)
+		if err != nil {
+			return 0, err
+		}
+		hashedPassword = sql.NullString{String: string(hash), Valid: true}
+	}
+
+	// The snippet and its files are inserted in a transaction, so that we
+	// never end up with a snippet which is missing some of its files.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Use the Exec() method on the transaction to execute the statement. The
+	// first parameter is the SQL statement, followed by the values for the
+	// placeholder parameters. This method returns a sql.Result type, which
+	// contains some basic information about what happened when the statement
+	// was executed.
+	result, err := tx.Exec(stmt, snippet.Title, snippet.Content, snippet.Language, snippet.Visibility, expires, snippet.BurnAfterReading, hashedPassword, snippet.UserID, forkedFrom)
 	if err != nil {
 		return 0, err
 	}
@@ -52,32 +212,47 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 		return 0, err
 	}
 
+	err = insertFiles(tx, int(id), snippet.Files)
+	if err != nil {
+		return 0, err
+	}
+
+	err = insertTags(tx, int(id), snippet.Tags)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
 	// The ID returned has the type int64, so we convert it to an int type
 	// before returning.
 	return int(id), nil
 }
 
-// This will return a specific snippet based on this id.
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	// Write the SQL statement we want to execute. Againt, I've split it over two
-	// lines for readability
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() AND id = ?`
+// This will return a specific snippet based on this id, provided that the
+// user with the given viewerID is allowed to see it. Pass a viewerID of 0 for
+// anonymous visitors. Get() never deletes burn-after-reading snippets, so it
+// must only be used where the content isn't shown to anyone other than the
+// owner. Use View() when displaying a snippet.
+func (m *SnippetModel) Get(id int, viewerID int) (*Snippet, error) {
+	// Write the SQL statement we want to execute. We join on the users table
+	// so that the author's name comes back along with the snippet.
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.id = ? AND ` + visibleTo
 
 	// Use the QueryRow() method on the connection pool to execute our
-	// SQL statement, passing in the untruted id variable as the value for the
-	// placeholder parameter. This returns a pointer to a sql.Row object which
+	// SQL statement, passing in the untruted id variable and the viewer ID as
+	// the values for the placeholder parameters. This returns a pointer to a sql.Row object which
 	// holds the result from the database.
-	row := m.DB.QueryRow(stmt, id)
-
-	// Initialize a pointer to a new zeroed Snippet struct.
-	s := &Snippet{}
+	row := m.DB.QueryRow(stmt, id, viewerID)
 
-	// Use row.Scan() to copy the values from each field in sql.Row to the
-	// corresponding field in the Snippet struct. Notice that the arguments
-	// to row.Scan are *pointer* to the place you want to copy the data into,
-	// and the number of arguments must be exactly the same as the number of
-	// columns returned by your statement.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// Use the scanSnippet() helper to copy the values from each field in
+	// sql.Row to the corresponding field in a new Snippet struct.
+	s, err := scanSnippet(row)
 	if err != nil {
 		// If the query returns no rows, then row.Scan() return a
 		// sql.ErrNoRows error. We use the errors.Is() function check for that
@@ -90,25 +265,147 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 		}
 	}
 
+	s.Files, err = loadFiles(m.DB, s.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	// If everything went OK then return the Snippet object.
 	return s, nil
 }
 
-// This will return the 10 most recently created snippets.
-func (m *SnippetModel) Lastest() ([]*Snippet, error) {
-	// Write the SQL statement we want to execute.
-	stmt := `SELECT id, title, content, created, expires FROM snippets WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+// This will return a specific snippet for display, with the same visibility
+// rules as Get(). If the snippet is marked as burn-after-reading it is deleted
+// in the same transaction, so it can only ever be viewed once.
+func (m *SnippetModel) View(id int, viewerID int) (*Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The FOR UPDATE clause locks the row until the transaction finishes. If
+	// two requests for a burn-after-reading snippet arrive at the same time,
+	// the second one blocks here until the first has deleted the row and
+	// committed, at which point it finds nothing and gets ErrNoRecord.
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.id = ? AND ` + visibleTo + `
+	FOR UPDATE`
+
+	s, err := scanSnippet(tx.QueryRow(stmt, id, viewerID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	// The files must be read before a burn-after-reading snippet is deleted,
+	// because deleting the snippet deletes its files too.
+	s.Files, err = loadFiles(tx, s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.BurnAfterReading {
+		_, err = tx.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// We'll use the Unlock method to check the password for a protected snippet,
+// in the same way that UserModel.Authenticate() checks account passwords. If
+// the snippet doesn't exist or has no password, ErrNoRecord is returned, and
+// if the password is wrong we return ErrInvalidCredentials.
+func (m *SnippetModel) Unlock(id int, password string) error {
+	var hashedPassword []byte
+
+	stmt := `SELECT s.hashed_password FROM snippets s
+	WHERE ` + notExpired + ` AND s.id = ? AND s.hashed_password IS NOT NULL`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// This will return one page of the current public snippets, in the order
+// given by the filters, along with the pagination metadata.
+func (m *SnippetModel) List(filters Filters) ([]*Snippet, Metadata, error) {
+	// Only list the snippets belonging to one user, or with one tag, if the
+	// filters ask for it. The conditions are added to both queries below, so
+	// we build them (and their arguments) up front.
+	where := notExpired + ` AND ` + listed
+	args := []any{}
+
+	if filters.UserID != 0 {
+		where += ` AND s.user_id = ?`
+		args = append(args, filters.UserID)
+	}
+
+	if filters.Tag != "" {
+		where += ` AND EXISTS (SELECT true FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id
+		WHERE st.snippet_id = s.id AND t.name = ?)`
+		args = append(args, filters.Tag)
+	}
+
+	// First count the total number of current snippets, so that we can work
+	// out how many pages there are.
+	var totalRecords int
+
+	stmt := `SELECT COUNT(*) FROM snippets s WHERE ` + where
+
+	err := m.DB.QueryRow(stmt, args...).Scan(&totalRecords)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// Write the SQL statement we want to execute. Placeholder parameters can
+	// only be used for values, not column names or keywords, so we have to
+	// interpolate the ORDER BY clause ourselves. That's safe because the sort
+	// column and direction always come from our safelist.
+	stmt = fmt.Sprintf(`SELECT `+snippetColumns+`
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE `+where+`
+	ORDER BY %s
+	LIMIT ? OFFSET ?`, filters.orderBy())
 
 	// Use the Query() method on the connection pool to execute our
 	// SQL statement. this returns a sql.Rows resulset containing the result of
 	// our query.
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, append(args, filters.limit(), filters.offset())...)
 	if err != nil {
-		return nil, err
+		return nil, Metadata{}, err
 	}
 
 	// We defer rows.Close() to ensure the sql.Rows resultset is
-	// always properly closed before the Latest() method returns. This defer
+	// always properly closed before the List() method returns. This defer
 	// statement should come *after* you check for an error from the Query()
 	// method. Otherwise, if Query() returns error, you we'll get a panic
 	// trying to close a nil resultset.
@@ -123,17 +420,11 @@ func (m *SnippetModel) Lastest() ([]*Snippet, error) {
 	// resultset automatically closes itself and frees-up the underlying
 	// database connection.
 	for rows.Next() {
-		// Create a pointer to a new zeroed Snippet struct.
-		s := &Snippet{}
-
-		// Use rows.Scan() to copy the values from each field in the row to the
-		// new Snippet object that we created. Again, the arguments to row.Scan()
-		// must be pointers to the place you want to copy the data into, and the
-		// number of arguments must be exactly the same as the number of
-		// columns returned by our statement.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		// Use scanSnippet() to copy the values from each field in the row to
+		// a new Snippet object.
+		s, err := scanSnippet(rows)
 		if err != nil {
-			return nil, err
+			return nil, Metadata{}, err
 		}
 
 		// Append it to the slice of snippets.
@@ -145,9 +436,76 @@ func (m *SnippetModel) Lastest() ([]*Snippet, error) {
 	// call this - don't assume that a successful iteration was completed
 	// over the whole resultset.
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, Metadata{}, err
+	}
+
+	// If everything went OK then return the Snippets slice and the metadata.
+	return snippets, CalculateMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// This will update the title, content, language and visibility of an
+// existing snippet. The expiry time is left untouched, so editing a snippet
+// doesn't extend its lifetime.
+// Before the snippet is changed, its current title and content are copied to
+// the snippet_revisions table so that we keep a full history of edits.
+func (m *SnippetModel) Update(id int, title string, content string, language string, visibility string) error {
+	// Both statements need to succeed or fail together, otherwise we could
+	// end up with an edit that has no record of the version it replaced. So
+	// we run them inside a transaction.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	// Defer a call to tx.Rollback() to ensure it is always called before the
+	// method returns. If the transaction has already been committed then the
+	// rollback is a no-op.
+	defer tx.Rollback()
+
+	stmt := `INSERT INTO snippet_revisions (snippet_id, title, content, created)
+	SELECT id, title, content, UTC_TIMESTAMP() FROM snippets WHERE id = ?`
+
+	_, err = tx.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE snippets SET title = ?, content = ?, language = ?, visibility = ?, updated = UTC_TIMESTAMP()
+	WHERE id = ?`
+
+	_, err = tx.Exec(stmt, title, content, language, visibility, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// This will delete a specific snippet based on its id.
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+// This will permanently delete up to limit snippets which expired before the
+// given time, returning the number of rows removed. Snippets which never
+// expire have a NULL expires column and are left alone. Deleting in batches
+// keeps each statement (and the locks it holds) short, so the purge doesn't
+// get in the way of requests which are being served at the same time.
+func (m *SnippetModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE expires IS NOT NULL AND expires <= ? LIMIT ?`
+
+	result, err := m.DB.Exec(stmt, before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
 	}
 
-	// If everything went OK then return the Snippets slice.
-	return snippets, nil
+	return int(n), nil
 }
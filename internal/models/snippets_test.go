@@ -0,0 +1,327 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestSnippetModelGet(t *testing.T) {
+	// Skip the test if the "-short" flag is provided when running the test.
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	tests := []struct {
+		name      string
+		snippetID int
+		wantUser  string
+		wantErr   error
+	}{
+		{
+			name:      "Valid ID",
+			snippetID: 1,
+			wantUser:  "Alice Jones",
+		},
+		{
+			name:      "Non-existent ID",
+			snippetID: 2,
+			wantErr:   ErrNoRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			m := SnippetModel{db}
+
+			s, err := m.Get(tt.snippetID, 0)
+			if tt.wantErr != nil {
+				assert.Equal(t, errors.Is(err, tt.wantErr), true)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, s.UserID, 1)
+			assert.Equal(t, s.UserName, tt.wantUser)
+		})
+	}
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	err := m.Update(1, "A new title", "A frog jumps in", "go", VisibilityPublic)
+	assert.NilError(t, err)
+
+	s, err := m.Get(1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Title, "A new title")
+	assert.Equal(t, s.Content, "A frog jumps in")
+
+	// The version which was replaced should have been recorded as a revision.
+	revisions, err := m.Revisions(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(revisions), 1)
+	assert.Equal(t, revisions[0].Title, "An old silent pond")
+	assert.Equal(t, revisions[0].Content, "An old silent pond...")
+}
+
+func TestSnippetModelList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	snippets, metadata, err := m.List(Filters{Page: 1, PageSize: 10, Sort: "-created"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].UserName, "Alice Jones")
+	assert.Equal(t, metadata.TotalRecords, 1)
+	assert.Equal(t, metadata.LastPage, 1)
+
+	// Asking for a page past the end should return no snippets, but still
+	// report the total.
+	snippets, metadata, err = m.List(Filters{Page: 2, PageSize: 10, Sort: "-created"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+	assert.Equal(t, metadata.TotalRecords, 1)
+
+	// Filtering by user should only return that user's snippets.
+	snippets, _, err = m.List(Filters{Page: 1, PageSize: 10, Sort: "-created", UserID: 1})
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+
+	snippets, metadata, err = m.List(Filters{Page: 1, PageSize: 10, Sort: "-created", UserID: 2})
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+	assert.Equal(t, metadata.TotalRecords, 0)
+}
+
+func TestSnippetModelView(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	// An ordinary snippet can be viewed as many times as you like.
+	for i := 0; i < 2; i++ {
+		_, err := m.View(1, 0)
+		assert.NilError(t, err)
+	}
+
+	// A burn-after-reading snippet can be viewed exactly once.
+	id, err := m.Insert(&Snippet{Title: "Secret", Content: "hunter2", Language: "plaintext", Visibility: VisibilityUnlisted, BurnAfterReading: true, UserID: 1})
+	assert.NilError(t, err)
+
+	s, err := m.View(id, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Content, "hunter2")
+
+	_, err = m.View(id, 0)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
+
+func TestSnippetModelDeleteExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	// Add three snippets which have already expired, and one which never
+	// expires.
+	for i := 0; i < 3; i++ {
+		_, err := m.Insert(&Snippet{Title: "Old", Content: "Old", Language: "plaintext", Visibility: VisibilityPublic, Expires: time.Now().Add(-time.Hour), UserID: 1})
+		assert.NilError(t, err)
+	}
+	_, err := m.Insert(&Snippet{Title: "Forever", Content: "Forever", Language: "plaintext", Visibility: VisibilityPublic, UserID: 1})
+	assert.NilError(t, err)
+
+	// Expired snippets are removed a batch at a time.
+	n, err := m.DeleteExpired(time.Now(), 2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 2)
+
+	n, err = m.DeleteExpired(time.Now(), 2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	n, err = m.DeleteExpired(time.Now(), 2)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+
+	// The seeded snippet and the never-expiring one are still there.
+	_, metadata, err := m.List(Filters{Page: 1, PageSize: 10, Sort: "-created"})
+	assert.NilError(t, err)
+	assert.Equal(t, metadata.TotalRecords, 2)
+}
+
+func TestSnippetModelVisibility(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	unlistedID, err := m.Insert(&Snippet{Title: "Unlisted", Content: "Unlisted", Language: "plaintext", Visibility: VisibilityUnlisted, UserID: 1})
+	assert.NilError(t, err)
+
+	privateID, err := m.Insert(&Snippet{Title: "Private", Content: "Private", Language: "plaintext", Visibility: VisibilityPrivate, UserID: 1})
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name      string
+		snippetID int
+		viewerID  int
+		wantErr   error
+	}{
+		{
+			name:      "Unlisted to anonymous",
+			snippetID: unlistedID,
+			viewerID:  0,
+		},
+		{
+			name:      "Private to owner",
+			snippetID: privateID,
+			viewerID:  1,
+		},
+		{
+			name:      "Private to anonymous",
+			snippetID: privateID,
+			viewerID:  0,
+			wantErr:   ErrNoRecord,
+		},
+		{
+			name:      "Private to another user",
+			snippetID: privateID,
+			viewerID:  2,
+			wantErr:   ErrNoRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.Get(tt.snippetID, tt.viewerID)
+			if tt.wantErr != nil {
+				assert.Equal(t, errors.Is(err, tt.wantErr), true)
+				return
+			}
+			assert.NilError(t, err)
+		})
+	}
+
+	// Neither of the new snippets should be listed.
+	_, metadata, err := m.List(Filters{Page: 1, PageSize: 10, Sort: "-created"})
+	assert.NilError(t, err)
+	assert.Equal(t, metadata.TotalRecords, 1)
+}
+
+func TestSnippetModelUnlock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	id, err := m.Insert(&Snippet{Title: "Locked", Content: "Locked", Language: "plaintext", Visibility: VisibilityUnlisted, Password: "letmein", UserID: 1})
+	assert.NilError(t, err)
+
+	s, err := m.Get(id, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, s.Protected, true)
+
+	err = m.Unlock(id, "letmein")
+	assert.NilError(t, err)
+
+	err = m.Unlock(id, "wrong")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	// The seeded snippet has no password, so there's nothing to unlock.
+	err = m.Unlock(1, "letmein")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
+
+func TestSnippetModelFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	id, err := m.Insert(&Snippet{
+		Title:      "Reproduction",
+		Content:    "package main",
+		Language:   "go",
+		Visibility: VisibilityPublic,
+		UserID:     1,
+		Files: []*File{
+			{Name: "go.mod", Language: "plaintext", Content: "module example.org/repro"},
+			{Name: "schema.sql", Language: "sql", Content: "SELECT 1;"},
+		},
+	})
+	assert.NilError(t, err)
+
+	s, err := m.Get(id, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(s.Files), 2)
+	assert.Equal(t, s.Files[0].Name, "go.mod")
+	assert.Equal(t, s.Files[1].Language, "sql")
+
+	// Snippets without any extra files get an empty slice.
+	s, err = m.Get(1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, len(s.Files), 0)
+}
+
+func TestSnippetModelForks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	id, err := m.Insert(&Snippet{Title: "Fork", Content: "An old silent pond... plop", Language: "plaintext", Visibility: VisibilityPublic, UserID: 1, ForkedFrom: 1})
+	assert.NilError(t, err)
+
+	fork, err := m.Get(id, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, fork.ForkedFrom, 1)
+
+	original, err := m.Get(1, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, original.Forks, 1)
+
+	// Deleting the original keeps the fork, but drops the link to it.
+	err = m.Delete(1)
+	assert.NilError(t, err)
+
+	fork, err = m.Get(id, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, fork.ForkedFrom, 0)
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+)
+
+type StarModelInterface interface {
+	Add(userID, snippetID int) error
+	Remove(userID, snippetID int) error
+	Exists(userID, snippetID int) (bool, error)
+	ForUser(userID int) ([]*Snippet, error)
+}
+
+// Define a StarModel type which wraps a database connection pool. A star is
+// a user's bookmark of a snippet, so that they can find it again on their
+// stars page.
+type StarModel struct {
+	DB *sql.DB
+}
+
+// This will star a snippet for a user. Starring a snippet which the user has
+// already starred does nothing, because INSERT IGNORE skips rows which would
+// break the primary key.
+func (m *StarModel) Add(userID, snippetID int) error {
+	stmt := `INSERT IGNORE INTO stars (user_id, snippet_id, created) VALUES(?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, snippetID)
+	return err
+}
+
+// This will remove a user's star from a snippet, if there is one.
+func (m *StarModel) Remove(userID, snippetID int) error {
+	_, err := m.DB.Exec(`DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`, userID, snippetID)
+	return err
+}
+
+// We'll use the Exists method to check whether a user has starred a snippet.
+func (m *StarModel) Exists(userID, snippetID int) (bool, error) {
+	var exists bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM stars WHERE user_id = ? AND snippet_id = ?)`
+
+	err := m.DB.QueryRow(stmt, userID, snippetID).Scan(&exists)
+
+	return exists, err
+}
+
+// This will return the current snippets a user has starred, most recently
+// starred first. Snippets which have since been made private by someone else
+// are left out, in the same way as they are everywhere else.
+func (m *StarModel) ForUser(userID int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+	FROM stars st
+	INNER JOIN snippets s ON s.id = st.snippet_id
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE st.user_id = ? AND ` + notExpired + ` AND ` + visibleTo + `
+	ORDER BY st.created DESC, s.id DESC`
+
+	rows, err := m.DB.Query(stmt, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
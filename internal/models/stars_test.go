@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestStarModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := StarModel{db}
+
+	exists, err := m.Exists(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, false)
+
+	// Starring the same snippet twice leaves a single star.
+	assert.NilError(t, m.Add(1, 1))
+	assert.NilError(t, m.Add(1, 1))
+
+	exists, err = m.Exists(1, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, exists, true)
+
+	snippets, err := m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].Stars, 1)
+
+	assert.NilError(t, m.Remove(1, 1))
+
+	snippets, err = m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 0)
+}
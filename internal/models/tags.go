@@ -0,0 +1,63 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Tags are short lower-case labels, like "billing" or "auth", which are used
+// to group snippets. A snippet can have up to MaxTags of them.
+const (
+	MaxTags      = 5
+	MaxTagLength = 30
+)
+
+// TagRX matches a valid tag: lower-case letters, digits and dashes, starting
+// with a letter or digit. Commas aren't allowed, which means we can safely
+// join a snippet's tags together with commas in a query.
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// The ParseTags() function splits comma-separated text like "Billing, auth"
+// into a list of tags. Each tag is trimmed and converted to lower case, and
+// empty or repeated tags are dropped. The tags aren't checked against TagRX,
+// so that callers can report any problems to the user.
+func ParseTags(s string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+
+	for _, tag := range strings.Split(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// The insertTags() function attaches the tags to a snippet, creating any
+// tags which don't exist yet. The ON DUPLICATE KEY UPDATE clause makes
+// LAST_INSERT_ID() return the ID of the existing tag when there is one, so
+// either way we get the tag's ID back from LastInsertId().
+func insertTags(q querier, snippetID int, tags []string) error {
+	for _, tag := range tags {
+		result, err := q.Exec(`INSERT INTO tags (name) VALUES(?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`, tag)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = q.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id) VALUES(?, ?)`, snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{
+			name: "Empty",
+			s:    "",
+			want: []string{},
+		},
+		{
+			name: "Spaces and case",
+			s:    " Billing,auth ,  INFRA",
+			want: []string{"billing", "auth", "infra"},
+		},
+		{
+			name: "Repeated and empty tags",
+			s:    "auth,,Auth, ,billing,",
+			want: []string{"auth", "billing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseTags(tt.s)
+			assert.Equal(t, strings.Join(got, "|"), strings.Join(tt.want, "|"))
+		})
+	}
+}
+
+func TestSnippetModelTags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := SnippetModel{db}
+
+	// The "auth" tag is shared by both snippets, so it should only be stored
+	// once.
+	id, err := m.Insert(&Snippet{Title: "Login query", Content: "SELECT 1;", Language: "sql", Visibility: VisibilityPublic, UserID: 1, Tags: []string{"billing", "auth"}})
+	assert.NilError(t, err)
+
+	_, err = m.Insert(&Snippet{Title: "Token query", Content: "SELECT 2;", Language: "sql", Visibility: VisibilityPublic, UserID: 1, Tags: []string{"auth"}})
+	assert.NilError(t, err)
+
+	s, err := m.Get(id, 0)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Join(s.Tags, ","), "auth,billing")
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM tags WHERE name = 'auth'`).Scan(&count)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 1)
+
+	snippets, metadata, err := m.List(Filters{Page: 1, PageSize: 10, Sort: "-created", Tag: "auth"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 2)
+	assert.Equal(t, metadata.TotalRecords, 2)
+
+	snippets, _, err = m.List(Filters{Page: 1, PageSize: 10, Sort: "-created", Tag: "billing"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(snippets), 1)
+	assert.Equal(t, snippets[0].ID, id)
+}
@@ -1,21 +1,139 @@
+CREATE TABLE users (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, name VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  hashed_password CHAR(60) NOT NULL,
+  created DATETIME NOT NULL,
+  verified BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+
 CREATE TABLE snippets (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, title VARCHAR(100) NOT NULL,
   content TEXT NOT NULL,
+  language VARCHAR(20) NOT NULL DEFAULT 'plaintext',
+  visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public',
   created DATETIME NOT NULL,
-  expires DATETIME NOT NULL
+  updated DATETIME NOT NULL,
+  expires DATETIME,
+  burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+  hashed_password CHAR(60),
+  user_id INTEGER NOT NULL,
+  forked_from INTEGER
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
 
-CREATE TABLE users (
-  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT, name VARCHAR(255) NOT NULL,
-  email VARCHAR(255) NOT NULL,
-  hashed_password CHAR(60) NOT NULL,
+CREATE FULLTEXT INDEX idx_snippets_fulltext ON snippets(title, content);
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_forked_from FOREIGN KEY (forked_from) REFERENCES snippets(id) ON DELETE SET NULL;
+
+CREATE TABLE snippet_revisions (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  content TEXT NOT NULL,
   created DATETIME NOT NULL
 );
 
-ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE snippet_files (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  language VARCHAR(20) NOT NULL DEFAULT 'plaintext',
+  content TEXT NOT NULL
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_snippet_id_name UNIQUE (snippet_id, name);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE tags (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(30) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+
+CREATE TABLE snippet_tags (
+  snippet_id INTEGER NOT NULL,
+  tag_id INTEGER NOT NULL,
+  PRIMARY KEY (snippet_id, tag_id)
+);
+
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
 
-INSERT INTO users (name, email, hashed_password, created) VALUES ( 'Alice Jones',
-'alice@example.com', '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG', '2022-01-01 10:00:00'
-);
\ No newline at end of file
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_tag_id FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE;
+
+CREATE TABLE comments (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  parent_id INTEGER,
+  line INTEGER,
+  body TEXT NOT NULL,
+  created DATETIME NOT NULL
+);
+
+ALTER TABLE comments ADD CONSTRAINT comments_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+ALTER TABLE comments ADD CONSTRAINT comments_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE comments ADD CONSTRAINT comments_fk_parent_id FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
+
+CREATE TABLE stars (
+  user_id INTEGER NOT NULL,
+  snippet_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_stars_snippet_id ON stars(snippet_id);
+
+ALTER TABLE stars ADD CONSTRAINT stars_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE stars ADD CONSTRAINT stars_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE api_tokens (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  hash BINARY(32) NOT NULL,
+  created DATETIME NOT NULL,
+  last_used DATETIME,
+  expires DATETIME
+);
+
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_uc_hash UNIQUE (hash);
+
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE TABLE one_time_tokens (
+  hash BINARY(32) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  scope VARCHAR(20) NOT NULL,
+  expires DATETIME NOT NULL
+);
+
+ALTER TABLE one_time_tokens ADD CONSTRAINT one_time_tokens_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE TABLE sessions (
+  token CHAR(43) PRIMARY KEY,
+  data BLOB NOT NULL,
+  expiry TIMESTAMP(6) NOT NULL
+);
+
+CREATE INDEX sessions_expiry_idx ON sessions (expiry);
+
+INSERT INTO users (name, email, hashed_password, created, verified) VALUES ( 'Alice Jones',
+'alice@example.com', '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG', '2022-01-01 10:00:00', TRUE
+);
+
+INSERT INTO snippets (title, content, created, updated, expires, user_id) VALUES (
+'An old silent pond', 'An old silent pond...', '2022-01-01 10:00:00', '2022-01-01 10:00:00', '2099-01-01 10:00:00', 1
+);
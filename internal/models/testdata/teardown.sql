@@ -1,2 +1,11 @@
-DROP TABLE users; 
-DROP TABLE snippets;
\ No newline at end of file
+DROP TABLE api_tokens;
+DROP TABLE one_time_tokens;
+DROP TABLE sessions;
+DROP TABLE snippet_revisions;
+DROP TABLE stars;
+DROP TABLE comments;
+DROP TABLE snippet_files;
+DROP TABLE snippet_tags;
+DROP TABLE tags;
+DROP TABLE snippets;
+DROP TABLE users;
\ No newline at end of file
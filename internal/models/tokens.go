@@ -0,0 +1,203 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+)
+
+type TokenModelInterface interface {
+	Insert(userID int, name string, expires time.Time) (string, error)
+	Authenticate(plaintext string) (int, error)
+	ForUser(userID int) ([]*Token, error)
+	Delete(userID int, id int) error
+	DeleteAllForUser(userID int) error
+	DeleteExpired(before time.Time, limit int) (int, error)
+}
+
+// Define a Token type to hold the details of a personal API token. We never
+// store the token itself, only a SHA-256 hash of it, so there's no field for
+// it here. A zero LastUsed time means the token hasn't been used yet, and a
+// zero Expires time means it never expires.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string
+	Created  time.Time
+	LastUsed time.Time
+	Expires  time.Time
+}
+
+// Define a TokenModel type which wraps a database connection pool.
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// The hashToken() function returns the SHA-256 hash of a plain-text token.
+// Unlike passwords, tokens are long random strings, so a fast hash is all we
+// need and we don't have to pay for bcrypt on every API request.
+func hashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// The generateToken() function fills a byte slice with 20 random bytes from
+// the operating system's CSPRNG, and encodes them as a base-32 string. That
+// gives us a 32 character token like "Y3QMGX3PJ3WLRL2YRTQGQ6KRHUIWVA7Z".
+func generateToken() (string, error) {
+	randomBytes := make([]byte, 20)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+// This will create a new token for the user and return it in plain text. The
+// plain-text token is never stored, so this is the only chance to show it to
+// the user. Pass a zero expires time for a token which never expires.
+func (m *TokenModel) Insert(userID int, name string, expires time.Time) (string, error) {
+	plaintext, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	var expiresAt sql.NullTime
+	if !expires.IsZero() {
+		expiresAt = sql.NullTime{Time: expires.UTC(), Valid: true}
+	}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, hash, created, expires)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	_, err = m.DB.Exec(stmt, userID, name, hashToken(plaintext), expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// We'll use the Authenticate method to find the user who owns a plain-text
+// token. If the token doesn't exist or has expired we return the
+// ErrInvalidCredentials error. Otherwise the token's last_used time is
+// updated and the user ID is returned.
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	var id, userID int
+
+	stmt := `SELECT id, user_id FROM api_tokens
+	WHERE hash = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+	err := m.DB.QueryRow(stmt, hashToken(plaintext)).Scan(&id, &userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	_, err = m.DB.Exec(`UPDATE api_tokens SET last_used = UTC_TIMESTAMP() WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// This will return all of a user's tokens, including expired ones, newest
+// first.
+func (m *TokenModel) ForUser(userID int) ([]*Token, error) {
+	stmt := `SELECT id, user_id, name, created, last_used, expires FROM api_tokens
+	WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+
+	for rows.Next() {
+		t := &Token{}
+
+		// The last_used and expires columns can both be NULL.
+		var lastUsed, expires sql.NullTime
+
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Created, &lastUsed, &expires)
+		if err != nil {
+			return nil, err
+		}
+
+		if lastUsed.Valid {
+			t.LastUsed = lastUsed.Time
+		}
+		if expires.Valid {
+			t.Expires = expires.Time
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// This will revoke one of a user's tokens. We include the user ID in the
+// WHERE clause so that nobody can revoke someone else's token, and return
+// ErrNoRecord if nothing was deleted.
+func (m *TokenModel) Delete(userID int, id int) error {
+	stmt := `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will revoke all of a user's tokens. We use it when the user changes
+// their password, in case the old one was leaked along with their tokens.
+func (m *TokenModel) DeleteAllForUser(userID int) error {
+	stmt := `DELETE FROM api_tokens WHERE user_id = ?`
+
+	_, err := m.DB.Exec(stmt, userID)
+	return err
+}
+
+// This will delete up to limit tokens which expired before the given time,
+// returning the number of rows removed. Tokens which never expire have a
+// NULL expires column and are left alone.
+func (m *TokenModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	stmt := `DELETE FROM api_tokens WHERE expires IS NOT NULL AND expires <= ? LIMIT ?`
+
+	result, err := m.DB.Exec(stmt, before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
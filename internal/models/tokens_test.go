@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"snippetbox.example.org/internal/assert"
+)
+
+func TestTokenModel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := TokenModel{db}
+
+	plaintext, err := m.Insert(1, "CI", time.Time{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(plaintext), 32)
+
+	expired, err := m.Insert(1, "Old laptop", time.Now().Add(-time.Hour))
+	assert.NilError(t, err)
+
+	// A valid token resolves to its owner and records when it was used.
+	userID, err := m.Authenticate(plaintext)
+	assert.NilError(t, err)
+	assert.Equal(t, userID, 1)
+
+	// Expired and unknown tokens are rejected.
+	_, err = m.Authenticate(expired)
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	_, err = m.Authenticate("not-a-real-token")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	tokens, err := m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 2)
+	assert.Equal(t, tokens[0].Name, "Old laptop")
+	assert.Equal(t, tokens[0].LastUsed.IsZero(), true)
+	assert.Equal(t, tokens[1].Name, "CI")
+	assert.Equal(t, tokens[1].LastUsed.IsZero(), false)
+	assert.Equal(t, tokens[1].Expires.IsZero(), true)
+
+	// Users can only revoke their own tokens.
+	err = m.Delete(2, tokens[1].ID)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+
+	err = m.Delete(1, tokens[1].ID)
+	assert.NilError(t, err)
+
+	_, err = m.Authenticate(plaintext)
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	// The purge deletes the expired token which is left.
+	n, err := m.DeleteExpired(time.Now(), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+
+	tokens, err = m.ForUser(1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(tokens), 0)
+
+	// All of a user's tokens can be revoked at once.
+	phone, err := m.Insert(1, "Phone", time.Time{})
+	assert.NilError(t, err)
+
+	err = m.DeleteAllForUser(1)
+	assert.NilError(t, err)
+
+	_, err = m.Authenticate(phone)
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+}
@@ -11,19 +11,26 @@ import (
 )
 
 type UserModelInterface interface {
-	Insert(name, email, password string) error
+	Insert(name, email, password string) (int, error)
 	Authenticate(email, password string) (int, error)
 	Exists(id int) (bool, error)
+	Get(id int) (*User, error)
+	GetByEmail(email string) (*User, error)
+	PasswordUpdate(id int, currentPassword, newPassword string) error
+	PasswordSet(id int, newPassword string) error
+	Verify(id int) error
 }
 
 // Define a new User type. Notice how the field names and types align
-// with the columns in the database "users" table?
+// with the columns in the database "users" table? Verified is true once the
+// user has confirmed their email address.
 type User struct {
 	ID             int
 	Name           string
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	Verified       bool
 }
 
 // Define a new UserModel type which wraps a database connection pool.
@@ -32,18 +39,20 @@ type UserModel struct {
 }
 
 // We'll use the Insert method to add a new record ro the "users" table.
-func (m *UserModel) Insert(name, email, password string) error {
+// New users start off unverified, and it returns the ID of the new user so
+// that we can send them a verification token.
+func (m *UserModel) Insert(name, email, password string) (int, error) {
 	// Create a bcrypt hash of the plain-text password.
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	stmt := `INSERT INTO users (name, email, hashed_password, created) VALUES(?,?,?, UTC_TIMESTAMP())`
 
 	// Use the Exec() method to insert the user details and hashed password
 	// into the users table.
-	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	result, err := m.DB.Exec(stmt, name, email, string(hashedPassword))
 	if err != nil {
 		// If this returns an error, we use the errors.As() function to check
 		// whether the error has the type *mysql.MySQLError. If it does, the
@@ -54,13 +63,18 @@ func (m *UserModel) Insert(name, email, password string) error {
 		var mySQLError *mysql.MySQLError
 		if errors.As(err, &mySQLError) {
 			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
-				return ErrDuplicateEmail
+				return 0, ErrDuplicateEmail
 			}
 		}
-		return err
+		return 0, err
 	}
 
-	return nil
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
 }
 
 // We'll use the Authenticate method to verify whether a user exists with
@@ -107,3 +121,91 @@ func (m *UserModel) Exists(id int) (bool, error) {
 
 	return exists, err
 }
+
+// We'll use the Get method to fetch the details of a specific user. The
+// hashed password is left out, because nothing which displays a user needs
+// it. If no matching user exists we return the ErrNoRecord error.
+func (m *UserModel) Get(id int) (*User, error) {
+	u := &User{}
+
+	stmt := `SELECT id, name, email, created, verified FROM users WHERE id = ?`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+// We'll use the GetByEmail method to look up a user by their email address,
+// in the same way as Get. If no matching user exists we return the
+// ErrNoRecord error.
+func (m *UserModel) GetByEmail(email string) (*User, error) {
+	u := &User{}
+
+	stmt := `SELECT id, name, email, created, verified FROM users WHERE email = ?`
+
+	err := m.DB.QueryRow(stmt, email).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+// We'll use the PasswordUpdate method to change a user's password. The
+// current password is checked first, in the same way as Authenticate, and we
+// return the ErrInvalidCredentials error if it doesn't match.
+func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+	var currentHashedPassword []byte
+
+	stmt := `SELECT hashed_password FROM users WHERE id = ?`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&currentHashedPassword)
+	if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+
+	return m.PasswordSet(id, newPassword)
+}
+
+// We'll use the PasswordSet method to replace a user's password without
+// checking the current one. Only call it once the user has proved who they
+// are some other way, such as with a password reset token.
+func (m *UserModel) PasswordSet(id int, newPassword string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE users SET hashed_password = ? WHERE id = ?`
+
+	_, err = m.DB.Exec(stmt, string(hashedPassword), id)
+	return err
+}
+
+// We'll use the Verify method to mark a user's email address as confirmed.
+func (m *UserModel) Verify(id int) error {
+	stmt := `UPDATE users SET verified = TRUE WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
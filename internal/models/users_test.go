@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"testing"
 
 	"snippetbox.example.org/internal/assert"
@@ -55,3 +56,88 @@ func TestUserModelExists(t *testing.T) {
 		})
 	}
 }
+
+func TestUserModelGet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := UserModel{db}
+
+	user, err := m.Get(1)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Name, "Alice Jones")
+	assert.Equal(t, user.Email, "alice@example.com")
+
+	_, err = m.Get(2)
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
+
+func TestUserModelPasswordUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := UserModel{db}
+
+	err := m.PasswordUpdate(1, "wrongPa$$word", "newPa$$word")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	err = m.PasswordUpdate(1, "pa$$word", "newPa$$word")
+	assert.NilError(t, err)
+
+	// The old password no longer works, and the new one does.
+	_, err = m.Authenticate("alice@example.com", "pa$$word")
+	assert.Equal(t, errors.Is(err, ErrInvalidCredentials), true)
+
+	id, err := m.Authenticate("alice@example.com", "newPa$$word")
+	assert.NilError(t, err)
+	assert.Equal(t, id, 1)
+}
+
+func TestUserModelGetByEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := UserModel{db}
+
+	user, err := m.GetByEmail("alice@example.com")
+	assert.NilError(t, err)
+	assert.Equal(t, user.ID, 1)
+	assert.Equal(t, user.Name, "Alice Jones")
+
+	_, err = m.GetByEmail("nobody@example.com")
+	assert.Equal(t, errors.Is(err, ErrNoRecord), true)
+}
+
+func TestUserModelVerify(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration test")
+	}
+
+	db := newTestDB(t)
+
+	m := UserModel{db}
+
+	// New users start off unverified.
+	id, err := m.Insert("Bob Smith", "bob@example.com", "pa$$word")
+	assert.NilError(t, err)
+
+	user, err := m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Verified, false)
+
+	err = m.Verify(id)
+	assert.NilError(t, err)
+
+	user, err = m.Get(id)
+	assert.NilError(t, err)
+	assert.Equal(t, user.Verified, true)
+}
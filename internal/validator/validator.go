@@ -1,8 +1,11 @@
 package validator
 
 import (
+	"errors"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 )
 
@@ -13,6 +16,11 @@ import (
 // variable is more performat than re-parsing the pattern each time we need it.
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// FilenameRX matches a plain file name like "main.go" or "go.mod", made of
+// letters, digits, dots, dashes and underscores. In particular it can't
+// contain a slash, so file names are always safe to use in a URL path.
+var FilenameRX = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
 // Define a new Validator type which contains a map of validation errors for our
 // form fields.
 // Add a new NonFieldErrors []string field to the struct, which we will use to
@@ -98,3 +106,48 @@ func MinChars(value string, n int) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// The daysRX pattern matches a whole number of days or weeks, like "30d" or
+// "2w". These units aren't supported by time.ParseDuration() so we handle
+// them ourselves.
+var daysRX = regexp.MustCompile(`^(\d+)([dw])$`)
+
+// ParseDuration() parses a duration string like "10m", "3h" or "1h30m" in
+// the same way as time.ParseDuration(), and also accepts a whole number of
+// days or weeks like "30d" or "2w". Only positive durations are accepted.
+func ParseDuration(value string) (time.Duration, error) {
+	var d time.Duration
+
+	if matches := daysRX.FindStringSubmatch(value); matches != nil {
+		// Cap the total number of days at 100 years, which keeps the result
+		// clear of overflowing time.Duration. We check n on its own first so
+		// that converting weeks to days can't overflow either.
+		days, err := strconv.Atoi(matches[1])
+		if err != nil || days > 36500 {
+			return 0, errors.New("duration is too long")
+		}
+
+		if matches[2] == "w" {
+			days *= 7
+		}
+
+		if days > 36500 {
+			return 0, errors.New("duration is too long")
+		}
+
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+
+		d, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if d <= 0 {
+		return 0, errors.New("duration must be positive")
+	}
+
+	return d, nil
+}
@@ -5,4 +5,36 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+// On the create snippet form, let users add and remove extra files. Each file
+// is a copy of the #file-template element. The form decoder reads the fields
+// as a slice, so whenever a file is removed we renumber the field names to
+// keep the indexes in order without any gaps.
+var files = document.getElementById("files");
+var fileTemplate = document.getElementById("file-template");
+var addFile = document.getElementById("add-file");
+
+function renumberFiles() {
+	var fieldsets = files.querySelectorAll("fieldset.file");
+	for (var i = 0; i < fieldsets.length; i++) {
+		var fields = fieldsets[i].querySelectorAll("[name^='files[']");
+		for (var j = 0; j < fields.length; j++) {
+			var name = fields[j].getAttribute("name");
+			fields[j].setAttribute("name", name.replace(/^files\[[^\]]*\]/, "files[" + i + "]"));
+		}
+	}
+}
+
+if (files && fileTemplate && addFile) {
+	addFile.addEventListener("click", function() {
+		files.appendChild(document.importNode(fileTemplate.content, true));
+		renumberFiles();
+	});
+
+	files.addEventListener("click", function(event) {
+		if (event.target.classList.contains("remove-file")) {
+			event.target.closest("fieldset.file").remove();
+			renumberFiles();
+		}
+	});
+}